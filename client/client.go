@@ -3,10 +3,16 @@ package client
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"maps"
+	"slices"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/internal/logging"
+	"github.com/s84662355/simple-message/metrics"
 )
 
 var (
@@ -14,6 +20,9 @@ var (
 	ErrConn    = errors.New("连接失败")
 )
 
+// DefaultStableDuration 连接保持超过该时长才视为稳定，断开后失败次数清零
+const DefaultStableDuration = 5 * time.Second
+
 type Client struct {
 	handler     map[uint32]connection.Handler
 	ctx         context.Context
@@ -22,24 +31,51 @@ type Client struct {
 	action      Action
 	done        chan struct{}
 	connPointer atomic.Pointer[connection.Connection]
+	connOptions []connection.Option
+	optionMu    sync.Mutex
+	policy      ReconnectPolicy
+	giveUp      func(err error)
+	state       atomic.Int32
+	notifier    stateNotifier
+	metrics     metrics.Sink
+	logger      *slog.Logger
+
+	stableDuration time.Duration
 }
 
 func NewClient(
 	handler map[uint32]connection.Handler,
 	maxDataLen uint32,
 	action Action,
+	opts ...Option,
 ) *Client {
 	c := &Client{
 		handler:    maps.Clone(handler),
 		action:     action,
 		maxDataLen: maxDataLen,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.policy == nil {
+		c.policy = DefaultReconnectPolicy()
+	}
+	if c.stableDuration <= 0 {
+		c.stableDuration = DefaultStableDuration
+	}
+	if c.metrics == nil {
+		c.metrics = metrics.Discard
+	}
+	if c.logger == nil {
+		c.logger = logging.Discard
+	}
 
 	c.ctx, c.cancel = context.WithCancel(context.Background())
 
 	c.done = make(chan struct{})
 	go func() {
 		defer close(c.done)
+		defer c.setState(StateStopped)
 		c.start()
 		c.cancel()
 	}()
@@ -53,58 +89,138 @@ func (c *Client) Stop() <-chan struct{} {
 }
 
 func (c *Client) start() {
-	for c.action != nil {
+	attempt := 0
+	for first := true; c.action != nil; first = false {
 		select {
 		case <-c.ctx.Done():
 			return
 		default:
 
 		}
-		c.dial()
+
+		if !first {
+			c.metrics.IncCounter(metrics.ClientReconnects, 1)
+			c.logger.Info("重新连接", slog.Int("attempt", attempt+1))
+		}
+		c.setState(StateConnecting)
+		stable, err := c.dial()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if stable {
+			// 连接保持稳定后断开，失败次数清零并立即重连
+			attempt = 0
+			continue
+		}
+
+		// 拨号失败或连接建立后很快断开(如被服务端拒绝、握手或认证失败)，按重连策略等待
+		attempt++
+		delay, ok := c.policy.Next(attempt)
+		if !ok {
+			c.logger.Error("放弃重连", slog.Int("attempt", attempt), slog.Any("error", err))
+			if c.giveUp != nil {
+				c.giveUp(err)
+			}
+			return
+		}
+
+		c.logger.Warn("连接失败", slog.Int("attempt", attempt), slog.Duration("retry_in", delay), slog.Any("error", err))
+		c.setState(StateBackoff)
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
 	}
 }
 
-func (c *Client) SendMsg(MsgID uint32, Data []byte) error {
-	return c.sendMsgContext(context.Background(), MsgID, Data)
+func (c *Client) SendMsg(MsgID uint32, Data []byte, opts ...connection.SendOption) error {
+	return c.sendMsgContext(context.Background(), MsgID, Data, opts)
 }
 
-func (c *Client) SendMsgContext(ctx context.Context, MsgID uint32, Data []byte) error {
-	return c.sendMsgContext(ctx, MsgID, Data)
+func (c *Client) SendMsgContext(ctx context.Context, MsgID uint32, Data []byte, opts ...connection.SendOption) error {
+	return c.sendMsgContext(ctx, MsgID, Data, opts)
 }
 
-func (c *Client) sendMsgContext(ctx context.Context, MsgID uint32, Data []byte) error {
+func (c *Client) sendMsgContext(ctx context.Context, MsgID uint32, Data []byte, opts []connection.SendOption) error {
 	conn := c.connPointer.Load()
 	if conn == nil {
 		return ErrConn
 	}
-	return conn.SendMsgContext(ctx, MsgID, Data)
+	return conn.SendMsgContext(ctx, MsgID, Data, opts...)
 }
 
-func (c *Client) dial() {
-	if conn, data, err := c.action.DialContext(c.ctx); err != nil {
-		return
-	} else {
-		defer conn.Close()
-		handlerManager := connection.NewHandlerManager(
-			conn,
-			c.handler,
-			c.maxDataLen,
-			c.action.ConnectedBegin,
-			data,
-		)
-		defer func() {
-			<-handlerManager.Stop()
-			c.action.ConnErr(c.ctx, handlerManager.GetConnection(), handlerManager.Err())
-		}()
+// SendMsgWithPriority 以指定优先级发送消息
+func (c *Client) SendMsgWithPriority(ctx context.Context, MsgID uint32, Data []byte, priority connection.Priority, opts ...connection.SendOption) error {
+	return c.sendMsgContext(connection.ContextWithPriority(ctx, priority), MsgID, Data, opts)
+}
 
-		c.connPointer.Store(handlerManager.GetConnection())
+// Call 通过当前连接发送请求并等待应答
+func (c *Client) Call(ctx context.Context, MsgID uint32, Data []byte, opts ...connection.SendOption) ([]byte, error) {
+	conn := c.connPointer.Load()
+	if conn == nil {
+		return nil, ErrConn
+	}
+	return conn.Call(ctx, MsgID, Data, opts...)
+}
 
-		select {
-		case <-c.ctx.Done():
-			return
-		case <-handlerManager.Ctx().Done():
-			return
-		}
+// dial 拨号并处理连接直到断开
+// 连接保持超过 stableDuration 时 stable 为true，否则返回拨号错误或连接断开的原因
+func (c *Client) dial() (stable bool, err error) {
+	conn, data, err := c.action.DialContext(c.ctx)
+	if err != nil {
+		c.metrics.IncCounter(metrics.ClientDialErrors, 1)
+		return false, err
+	}
+	defer conn.Close()
+	handlerManager := connection.NewHandlerManager(
+		conn,
+		c.handler,
+		c.maxDataLen,
+		c.action.ConnectedBegin,
+		data,
+		c.getConnOptions()...,
+	)
+	connectedAt := time.Now()
+	defer func() {
+		<-handlerManager.Stop()
+		connected := time.Since(connectedAt)
+		c.metrics.Observe(metrics.ClientConnectedSeconds, connected.Seconds())
+		handlerManager.Protect(0, func() {
+			c.action.ConnErr(c.ctx, handlerManager.GetConnection(), handlerManager.Err())
+		})
+		stable = connected >= c.stableDuration
+		err = handlerManager.Err()
+	}()
 
+	c.connPointer.Store(handlerManager.GetConnection())
+	c.setState(StateConnected)
+
+	select {
+	case <-c.ctx.Done():
+	case <-handlerManager.Ctx().Done():
 	}
+	return
+}
+
+// Use 追加消息处理器中间件，对之后建立的连接生效
+func (c *Client) Use(middlewares ...connection.Middleware) {
+	c.optionMu.Lock()
+	defer c.optionMu.Unlock()
+	c.connOptions = append(c.connOptions, connection.WithMiddleware(middlewares...))
+}
+
+// UseOutbound 追加出站拦截器，对之后建立的连接生效
+func (c *Client) UseOutbound(interceptors ...connection.SendInterceptor) {
+	c.optionMu.Lock()
+	defer c.optionMu.Unlock()
+	c.connOptions = append(c.connOptions, connection.WithSendInterceptor(interceptors...))
+}
+
+func (c *Client) getConnOptions() []connection.Option {
+	c.optionMu.Lock()
+	defer c.optionMu.Unlock()
+	return slices.Clone(c.connOptions)
 }
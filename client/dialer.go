@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// Dialer 拨号器，实现 Action.DialContext，可以嵌入到 Action 的实现中
+type Dialer interface {
+	DialContext(ctx context.Context) (connection.Conn, any, error)
+}
+
+// NetDialer 通过 net.Dialer 拨号，Data 作为连接数据返回
+type NetDialer struct {
+	Network string
+	Address string
+	Dialer  net.Dialer
+	Data    any
+}
+
+// NewTCPDialer TCP拨号器
+func NewTCPDialer(address string) *NetDialer {
+	return &NetDialer{
+		Network: "tcp",
+		Address: address,
+	}
+}
+
+// NewUnixDialer Unix域套接字拨号器
+func NewUnixDialer(path string) *NetDialer {
+	return &NetDialer{
+		Network: "unix",
+		Address: path,
+	}
+}
+
+func (d *NetDialer) DialContext(ctx context.Context) (connection.Conn, any, error) {
+	conn, err := d.Dialer.DialContext(ctx, d.Network, d.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, d.Data, nil
+}
+
+// TLSDialer TLS拨号器，Config 为nil时使用默认配置
+type TLSDialer struct {
+	NetDialer
+	Config *tls.Config
+}
+
+func NewTLSDialer(address string, config *tls.Config) *TLSDialer {
+	return &TLSDialer{
+		NetDialer: NetDialer{
+			Network: "tcp",
+			Address: address,
+		},
+		Config: config,
+	}
+}
+
+func (d *TLSDialer) DialContext(ctx context.Context) (connection.Conn, any, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &d.Dialer,
+		Config:    d.Config,
+	}
+	conn, err := dialer.DialContext(ctx, d.Network, d.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, d.Data, nil
+}
+
+var (
+	_ Dialer = (*NetDialer)(nil)
+	_ Dialer = (*TLSDialer)(nil)
+)
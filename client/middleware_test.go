@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// pipeAction 拨号时取出测试准备的连接
+type pipeAction struct {
+	conns chan net.Conn
+}
+
+func (a *pipeAction) DialContext(ctx context.Context) (connection.Conn, any, error) {
+	select {
+	case conn := <-a.conns:
+		return conn, nil, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (a *pipeAction) ConnErr(ctx context.Context, conn *connection.Connection, err error) {}
+
+func (a *pipeAction) ConnectedBegin(ctx context.Context, conn *connection.Connection) {
+	<-ctx.Done()
+}
+
+// Use 与 UseOutbound 对之后建立的连接生效
+func TestClientUse(t *testing.T) {
+	action := &pipeAction{conns: make(chan net.Conn)}
+	got := make(chan []byte, 1)
+	c := NewClient(map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		r.GetConnection().SendMsg(2, r.GetData())
+	})}, 0, action)
+	defer func() { <-c.Stop() }()
+	c.Use(func(next connection.Handler) connection.Handler {
+		return connection.HandlerFunc(func(r connection.IRequest) {
+			r.GetConnection().StoreProperty("middleware", true)
+			next.Handle(r)
+		})
+	})
+	c.UseOutbound(func(next connection.SendFunc) connection.SendFunc {
+		return func(ctx context.Context, conn *connection.Connection, message *protocol.Message) error {
+			if _, ok := conn.LoadProperty("middleware"); !ok {
+				return next(ctx, conn, message)
+			}
+			r := *message
+			r.Data = append(r.Data, "!"...)
+			return next(ctx, conn, &r)
+		}
+	})
+
+	a, b := net.Pipe()
+	action.conns <- a
+	peer := connection.NewHandlerManager(b, map[uint32]connection.Handler{2: connection.HandlerFunc(func(r connection.IRequest) {
+		got <- r.GetData()
+	})}, 0, func(ctx context.Context, conn *connection.Connection) {}, nil)
+	defer func() { <-peer.Stop() }()
+	if err := peer.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-got:
+		if string(data) != "hi!" {
+			t.Fatalf("data = %q", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待超时")
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/metrics"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// Option 客户端配置项
+type Option func(*Client)
+
+// WithConnOptions 追加连接使用的配置项
+func WithConnOptions(opts ...connection.Option) Option {
+	return func(c *Client) {
+		c.connOptions = append(c.connOptions, opts...)
+	}
+}
+
+// WithCodec 指定消息编解码器，指定后 maxDataLen 由编解码器自行约束
+func WithCodec(codec protocol.Codec) Option {
+	return WithConnOptions(connection.WithCodec(codec))
+}
+
+// WithReconnectPolicy 指定重连策略，默认 DefaultReconnectPolicy
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(c *Client) {
+		c.policy = policy
+	}
+}
+
+// WithGiveUp 重连策略放弃重连时的回调，err 为最后一次拨号错误或连接断开的原因，回调后客户端停止
+func WithGiveUp(giveUp func(err error)) Option {
+	return func(c *Client) {
+		c.giveUp = giveUp
+	}
+}
+
+// WithStableDuration 连接保持超过d才视为稳定，稳定的连接断开后失败次数清零并立即重连
+// 未稳定就断开的连接计为一次失败，按重连策略等待，默认 DefaultStableDuration
+func WithStableDuration(d time.Duration) Option {
+	return func(c *Client) {
+		c.stableDuration = d
+	}
+}
+
+// WithMetrics 将客户端及其连接的指标记录到sink
+func WithMetrics(sink metrics.Sink) Option {
+	return func(c *Client) {
+		c.metrics = sink
+		c.connOptions = append(c.connOptions, connection.WithMetrics(sink))
+	}
+}
+
+// WithLogger 将拨号失败、重连以及连接的事件输出到logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+		c.connOptions = append(c.connOptions, connection.WithLogger(logger))
+	}
+}
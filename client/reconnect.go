@@ -0,0 +1,81 @@
+package client
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	DefaultMaxBackoff = 5 * time.Minute        // ExponentialBackoff 未指定 Max 时的最大等待时长
+	DefaultBackoff    = 100 * time.Millisecond // 未指定 Interval 或 Initial 时的等待时长，避免不停地重连
+)
+
+// ReconnectPolicy 重连策略
+// attempt 为连续失败的次数(从1开始)，拨号失败与未保持稳定就断开的连接都计为失败
+// 返回等待时长，返回false表示放弃重连
+type ReconnectPolicy interface {
+	Next(attempt int) (time.Duration, bool)
+}
+
+// FixedBackoff 固定间隔重连
+type FixedBackoff struct {
+	Interval    time.Duration // 重连间隔，小于等于0时为 DefaultBackoff
+	MaxAttempts int           // 最大连续失败次数，0表示不限制
+}
+
+func (b *FixedBackoff) Next(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	if b.Interval <= 0 {
+		return DefaultBackoff, true
+	}
+	return b.Interval, true
+}
+
+// ExponentialBackoff 指数退避重连
+type ExponentialBackoff struct {
+	Initial     time.Duration // 首次等待时长，小于等于0时为 DefaultBackoff
+	Max         time.Duration // 最大等待时长，0时为 DefaultMaxBackoff
+	Multiplier  float64       // 每次失败后的倍数，小于等于1时按2处理
+	Jitter      float64       // 随机抖动比例[0,1]，等待时长在[d*(1-Jitter), d]之间
+	MaxAttempts int           // 最大连续失败次数，0表示不限制
+}
+
+func (b *ExponentialBackoff) Next(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	maxDelay := b.Max
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxBackoff
+	}
+	d := float64(b.Initial)
+	if d <= 0 {
+		d = float64(DefaultBackoff)
+	}
+	for i := 1; i < attempt && d < float64(maxDelay); i++ {
+		d *= multiplier
+	}
+	if d > float64(maxDelay) {
+		d = float64(maxDelay)
+	}
+	if b.Jitter > 0 {
+		d -= d * min(b.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(d), true
+}
+
+// DefaultReconnectPolicy 未指定重连策略时使用
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return &ExponentialBackoff{
+		Initial:    DefaultBackoff,
+		Max:        30 * time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+func TestExponentialBackoffMax(t *testing.T) {
+	b := &ExponentialBackoff{Initial: 100 * time.Millisecond}
+	for attempt := 1; attempt <= 100; attempt++ {
+		d, ok := b.Next(attempt)
+		if !ok {
+			t.Fatalf("attempt %d 放弃重连", attempt)
+		}
+		if d < 0 || d > DefaultMaxBackoff {
+			t.Fatalf("attempt %d 等待时长 %v 超出范围", attempt, d)
+		}
+	}
+	b.Max = time.Second
+	if d, _ := b.Next(50); d != time.Second {
+		t.Fatalf("等待时长 %v，期望 %v", d, time.Second)
+	}
+}
+
+// 零值的重连策略使用 DefaultBackoff，不会立即重连
+func TestZeroValueBackoff(t *testing.T) {
+	for name, policy := range map[string]ReconnectPolicy{
+		"FixedBackoff":       &FixedBackoff{},
+		"ExponentialBackoff": &ExponentialBackoff{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for attempt := 1; attempt <= 3; attempt++ {
+				d, ok := policy.Next(attempt)
+				if !ok {
+					t.Fatalf("attempt %d 放弃重连", attempt)
+				}
+				if d < DefaultBackoff {
+					t.Fatalf("attempt %d 等待时长 %v，期望不小于 %v", attempt, d, DefaultBackoff)
+				}
+			}
+		})
+	}
+}
+
+type closingAction struct {
+	addr  string
+	dials atomic.Int32
+}
+
+func (a *closingAction) DialContext(ctx context.Context) (connection.Conn, any, error) {
+	a.dials.Add(1)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", a.addr)
+	return conn, nil, err
+}
+
+func (a *closingAction) ConnErr(ctx context.Context, conn *connection.Connection, err error) {}
+
+func (a *closingAction) ConnectedBegin(ctx context.Context, conn *connection.Connection) {
+	<-ctx.Done()
+}
+
+// 服务端接受后立即关闭连接时，客户端按重连策略等待而不是立即重连
+func TestReconnectBackoffAfterDisconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	action := &closingAction{addr: l.Addr().String()}
+	c := NewClient(nil, 0, action, WithReconnectPolicy(&FixedBackoff{Interval: 100 * time.Millisecond}))
+	time.Sleep(500 * time.Millisecond)
+	<-c.Stop()
+	if n := action.dials.Load(); n > 10 {
+		t.Fatalf("500ms内拨号%d次", n)
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import "sync"
+
+// State 客户端连接状态
+type State int32
+
+const (
+	StateConnecting State = iota // 正在拨号
+	StateConnected               // 已连接
+	StateBackoff                 // 拨号失败，等待重连
+	StateStopped                 // 已停止
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateBackoff:
+		return "Backoff"
+	case StateStopped:
+		return "Stopped"
+	default:
+		return "Unknown"
+	}
+}
+
+const stateChanSize = 8
+
+type stateNotifier struct {
+	mu          sync.Mutex
+	subscribers map[chan State]struct{}
+	stopped     bool
+}
+
+// State 当前连接状态
+func (c *Client) State() State {
+	return State(c.state.Load())
+}
+
+// Subscribe 订阅连接状态变化，订阅后先收到当前状态，订阅者消费过慢时丢弃最旧的状态，客户端停止后通道被关闭
+// 返回的函数用于取消订阅
+func (c *Client) Subscribe() (<-chan State, func()) {
+	n := &c.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch := make(chan State, stateChanSize)
+	if n.stopped {
+		ch <- StateStopped
+		close(ch)
+		return ch, func() {}
+	}
+	if n.subscribers == nil {
+		n.subscribers = make(map[chan State]struct{})
+	}
+	n.subscribers[ch] = struct{}{}
+	ch <- c.State()
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if _, ok := n.subscribers[ch]; ok {
+			delete(n.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (c *Client) setState(s State) {
+	n := &c.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopped || State(c.state.Swap(int32(s))) == s {
+		return
+	}
+	for ch := range n.subscribers {
+		select {
+		case ch <- s:
+		default:
+			// 只有持锁时才会写入，丢弃一个最旧的状态后必有空位
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- s
+		}
+		if s == StateStopped {
+			close(ch)
+		}
+	}
+	if s == StateStopped {
+		n.stopped = true
+		n.subscribers = nil
+	}
+}
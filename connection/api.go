@@ -13,6 +13,8 @@ type Conn interface {
 var (
 	ErrIsClose     = errors.New("已关闭")
 	ErrKeyNotFound = errors.New("属性不存在")
+	ErrIdleTimeout = errors.New("连接空闲超时")
+	ErrGoingAway   = errors.New("对端即将关闭连接")
 )
 
 type ConnectedBegin func(ctx context.Context, conn *Connection)
@@ -0,0 +1,105 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrAuthFailed  = errors.New("认证失败")
+	ErrAuthTimeout = errors.New("认证超时")
+)
+
+const DefaultAuthTimeout = 10 * time.Second
+
+// Authenticator 认证器，认证完成前收到的非白名单消息依次交给 Authenticate 处理，不会分发给处理器
+// 返回非nil的身份表示认证成功，返回错误时以 ErrAuthFailed 关闭连接，均为nil时继续等待下一个消息
+// Authenticate 在读协程中执行，可以通过 request.Reply 应答 Call 请求
+type Authenticator interface {
+	Authenticate(ctx context.Context, request IRequest) (identity any, err error)
+}
+
+type AuthenticatorFunc func(ctx context.Context, request IRequest) (identity any, err error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, request IRequest) (any, error) {
+	return f(ctx, request)
+}
+
+// 认证状态
+const (
+	authPending int32 = iota
+	authDone
+	authExpired
+)
+
+type identity struct {
+	v any
+}
+
+// startAuth 开始认证阶段，超时未认证则关闭连接
+func (h *HandlerManager) startAuth() {
+	if h.cfg.authenticator == nil {
+		h.authState.Store(authDone)
+		close(h.authed)
+		return
+	}
+	h.authTimer = time.AfterFunc(h.cfg.authTimeout, func() {
+		if h.authState.CompareAndSwap(authPending, authExpired) {
+			h.merr(ErrAuthTimeout)
+			h.stop()
+		}
+	})
+	context.AfterFunc(h.ctx, func() {
+		h.authTimer.Stop()
+	})
+}
+
+// allowed 认证完成前只允许白名单中的消息分发给处理器
+func (h *HandlerManager) allowed(msgID uint32) bool {
+	if h.authState.Load() == authDone {
+		return true
+	}
+	_, ok := h.cfg.authWhitelist[msgID]
+	return ok
+}
+
+// authenticate 在读协程中调用认证器，返回错误时结束读协程
+func (h *HandlerManager) authenticate(r *Request) error {
+	var (
+		id  any
+		err error
+	)
+	if !h.Protect(r.msgID, func() {
+		id, err = h.cfg.authenticator.Authenticate(h.ctx, r)
+	}) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	}
+	if id == nil {
+		return nil
+	}
+	if !h.authState.CompareAndSwap(authPending, authDone) {
+		return ErrAuthTimeout
+	}
+	h.authTimer.Stop()
+	h.conn.identity.Store(&identity{v: id})
+	close(h.authed)
+	return nil
+}
+
+// Identity 认证器返回的身份，未认证时为nil
+func (C *Connection) Identity() any {
+	if id := C.identity.Load(); id != nil {
+		return id.v
+	}
+	return nil
+}
+
+// Authenticated 是否已通过认证
+func (C *Connection) Authenticated() bool {
+	return C.identity.Load() != nil
+}
@@ -0,0 +1,126 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 认证完成前白名单消息照常分发，其余消息交给认证器，不分发给处理器
+func TestAuthWhitelist(t *testing.T) {
+	got := make(chan uint32, 4)
+	authed := make(chan []byte, 4)
+	handler := map[uint32]Handler{
+		1: HandlerFunc(func(r IRequest) { got <- r.GetMsgID() }),
+		2: HandlerFunc(func(r IRequest) { got <- r.GetMsgID() }),
+	}
+	auth := AuthenticatorFunc(func(ctx context.Context, r IRequest) (any, error) {
+		authed <- r.GetData()
+		if string(r.GetData()) == "token" {
+			return "alice", nil
+		}
+		return nil, nil
+	})
+	server, client := newPair(t, handler, []Option{WithAuthenticator(auth, time.Minute, 1)})
+
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if id := recv(t, got); id != 1 {
+		t.Fatalf("MsgID = %d，期望 1", id)
+	}
+	if err := client.GetConnection().SendMsg(2, []byte("guess")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, authed); string(data) != "guess" {
+		t.Fatalf("认证器收到 %q", data)
+	}
+	select {
+	case id := <-got:
+		t.Fatalf("认证前分发了消息 %d", id)
+	default:
+	}
+	if server.GetConnection().Authenticated() {
+		t.Fatal("未返回身份时不应通过认证")
+	}
+
+	if err := client.GetConnection().SendMsg(2, []byte("token")); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, authed)
+	eventually(t, server.GetConnection().Authenticated)
+	if id := server.GetConnection().Identity(); id != "alice" {
+		t.Fatalf("Identity = %v", id)
+	}
+	// 认证后消息不再经过认证器
+	if err := client.GetConnection().SendMsg(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if id := recv(t, got); id != 2 {
+		t.Fatalf("MsgID = %d，期望 2", id)
+	}
+	select {
+	case <-authed:
+		t.Fatal("认证后消息仍交给了认证器")
+	default:
+	}
+}
+
+func TestAuthFailed(t *testing.T) {
+	auth := AuthenticatorFunc(func(ctx context.Context, r IRequest) (any, error) {
+		return nil, errors.New("bad token")
+	})
+	server, client := newPair(t, nil, []Option{WithAuthenticator(auth, time.Minute)})
+
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, server)
+	if err := server.Err(); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("err = %v，期望 ErrAuthFailed", err)
+	}
+}
+
+func TestAuthTimeout(t *testing.T) {
+	auth := AuthenticatorFunc(func(ctx context.Context, r IRequest) (any, error) {
+		return nil, nil
+	})
+	server, _ := newPair(t, nil, []Option{WithAuthenticator(auth, 50*time.Millisecond)})
+
+	waitDone(t, server)
+	if err := server.Err(); !errors.Is(err, ErrAuthTimeout) {
+		t.Fatalf("err = %v，期望 ErrAuthTimeout", err)
+	}
+}
+
+// ConnectedBegin 在认证完成后才执行
+func TestAuthConnectedBegin(t *testing.T) {
+	begun := make(chan any, 1)
+	auth := AuthenticatorFunc(func(ctx context.Context, r IRequest) (any, error) {
+		return string(r.GetData()), nil
+	})
+	a, b := net.Pipe()
+	server := NewHandlerManager(a, nil, 0, func(ctx context.Context, conn *Connection) {
+		begun <- conn.Identity()
+		<-ctx.Done()
+	}, nil, WithAuthenticator(auth, time.Minute))
+	client := NewHandlerManager(b, nil, 0, func(ctx context.Context, conn *Connection) { <-ctx.Done() }, nil)
+	t.Cleanup(func() {
+		<-client.Stop()
+		<-server.Stop()
+	})
+
+	select {
+	case <-begun:
+		t.Fatal("认证前执行了 ConnectedBegin")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := client.GetConnection().SendMsg(1, []byte("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if id := recv(t, begun); id != "alice" {
+		t.Fatalf("ConnectedBegin 中 Identity = %v", id)
+	}
+}
@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+var (
+	ErrReservedMsgID = errors.New("消息ID为框架保留")
+	ErrNotRequest    = errors.New("不是请求消息,无法应答")
+	ErrReplied       = errors.New("已应答")
+)
+
+// ReplyError 对端通过 IRequest.ReplyError 返回的错误
+type ReplyError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("远程调用错误 code=%d: %s", e.Code, e.Message)
+}
+
+func encodeReplyError(code uint32, msg string) []byte {
+	b := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(msg)), code)
+	return append(b, msg...)
+}
+
+func decodeReplyError(data []byte) error {
+	if len(data) < 4 {
+		return protocol.ErrExtFrame
+	}
+	return &ReplyError{
+		Code:    binary.BigEndian.Uint32(data),
+		Message: string(data[4:]),
+	}
+}
+
+// Call 发送请求并等待对端应答，超时和取消由ctx控制
+// 请求按扩展帧发送，Data 最多为 maxDataLen-protocol.ExtSeqOverhead 字节，携带头部时还要减去 2+Header.Size()
+// 超过时返回 protocol.ErrDataLength
+func (C *Connection) Call(ctx context.Context, MsgID uint32, Data []byte, opts ...SendOption) ([]byte, error) {
+	if MsgID >= protocol.ReservedMsgID {
+		return nil, ErrReservedMsgID
+	}
+	message, err := C.newMessage(MsgID, Data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := C.seq.Add(1)
+	replyChan := make(chan *protocol.Message, 1)
+	C.pending.Store(seq, replyChan)
+	defer C.pending.Delete(seq)
+
+	message.Flags = protocol.FlagRequest
+	message.Seq = seq
+	if err := C.send(ctx, message); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-C.ctx.Done():
+		return nil, ErrIsClose
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case reply := <-replyChan:
+		if reply.Flags&protocol.FlagError != 0 {
+			return nil, decodeReplyError(reply.Data)
+		}
+		return reply.Data, nil
+	}
+}
+
+// deliverReply 将应答交给等待中的Call，无人等待时丢弃
+func (C *Connection) deliverReply(message *protocol.Message) {
+	if v, ok := C.pending.LoadAndDelete(message.Seq); ok {
+		v.(chan *protocol.Message) <- message
+	}
+}
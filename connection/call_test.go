@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// 请求与应答的数据最多为 maxDataLen-protocol.ExtSeqOverhead 字节
+func TestCallDataLimit(t *testing.T) {
+	replied := make(chan error, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		replied <- r.Reply(make([]byte, int(protocol.MaxDataLen-protocol.ExtSeqOverhead)+1))
+	}), 2: HandlerFunc(func(r IRequest) {
+		replied <- r.Reply(r.GetData())
+	})}
+	_, client := newPair(t, handler, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	limit := protocol.MaxDataLen - protocol.ExtSeqOverhead
+	resp, err := client.GetConnection().Call(ctx, 2, make([]byte, limit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint32(len(resp)) != limit {
+		t.Fatalf("应答 %d 字节，期望 %d 字节", len(resp), limit)
+	}
+	if err := recv(t, replied); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetConnection().Call(ctx, 2, make([]byte, limit+1)); !errors.Is(err, protocol.ErrDataLength) {
+		t.Fatalf("err = %v，期望 protocol.ErrDataLength", err)
+	}
+
+	callCtx, callCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer callCancel()
+	client.GetConnection().Call(callCtx, 1, nil)
+	if err := recv(t, replied); !errors.Is(err, protocol.ErrDataLength) {
+		t.Fatalf("Reply err = %v，期望 protocol.ErrDataLength", err)
+	}
+}
@@ -0,0 +1,53 @@
+package connection
+
+import (
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// 校验和协商帧的数据
+const (
+	checksumAdvert byte = iota + 1 // 本端能够校验对端发送的带校验和的帧
+	checksumSwitch                 // 本端之后发送的帧都带校验和
+)
+
+func (h *HandlerManager) checksumCodec() (protocol.Codec, bool) {
+	if !h.cfg.checksum {
+		return nil, false
+	}
+	c, ok := h.codec.(protocol.ChecksumCodec)
+	if !ok {
+		return nil, false
+	}
+	return c.Checksum(), true
+}
+
+// handleChecksum 收到通告后回复切换帧，收到切换帧后入站帧按带校验和的格式解析
+func (h *HandlerManager) handleChecksum(data []byte) error {
+	if len(data) != 1 {
+		return protocol.ErrExtFrame
+	}
+	codec, ok := h.checksumCodec()
+	switch data[0] {
+	case checksumAdvert:
+		if ok {
+			h.enqueueControl(&protocol.Message{MsgID: protocol.ChecksumMsgID, Data: []byte{checksumSwitch}})
+		}
+	case checksumSwitch:
+		if !ok {
+			// 本端未通告，对端不应切换
+			return protocol.ErrExtFrame
+		}
+		h.inCodec = codec
+	}
+	return nil
+}
+
+// switchChecksum 切换帧写入后，之后的出站帧都带校验和，只在发送协程中调用
+func (h *HandlerManager) switchChecksum(message *protocol.Message) {
+	if message.MsgID != protocol.ChecksumMsgID || len(message.Data) != 1 || message.Data[0] != checksumSwitch {
+		return
+	}
+	if codec, ok := h.checksumCodec(); ok {
+		h.outCodec = codec
+	}
+}
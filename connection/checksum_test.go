@@ -0,0 +1,126 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// newRawPeer 建立开启校验和的连接，对端为直接读写帧的原始连接，返回前读取本端的通告帧
+func newRawPeer(t *testing.T, handler map[uint32]Handler) (*HandlerManager, net.Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	h := NewHandlerManager(a, handler, 0, func(ctx context.Context, conn *Connection) {}, nil, WithChecksum())
+	t.Cleanup(func() {
+		b.Close()
+		<-h.Stop()
+	})
+	m, err := protocol.NewDecoder(0).Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MsgID != protocol.ChecksumMsgID || len(m.Data) != 1 || m.Data[0] != checksumAdvert {
+		t.Fatalf("首个帧 = %x %v，期望校验和通告", m.MsgID, m.Data)
+	}
+	return h, b
+}
+
+// 收到切换帧之前按普通格式解析，之后按带校验和的格式解析
+func TestChecksumSwitchInbound(t *testing.T) {
+	got := make(chan []byte, 2)
+	h, peer := newRawPeer(t, map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })})
+	plain := protocol.NewDecoder(0)
+	crc := plain.Checksum()
+
+	if err := plain.MarshalMessage(peer, &protocol.Message{MsgID: 1, Data: []byte("plain")}); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "plain" {
+		t.Fatalf("data = %q", data)
+	}
+	if err := plain.MarshalMessage(peer, &protocol.Message{MsgID: protocol.ChecksumMsgID, Data: []byte{checksumSwitch}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := crc.MarshalMessage(peer, &protocol.Message{MsgID: 1, Data: []byte("crc")}); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "crc" {
+		t.Fatalf("data = %q", data)
+	}
+
+	// 篡改数据后校验和不匹配
+	frame := binary.BigEndian.AppendUint32(nil, 1)
+	frame = binary.BigEndian.AppendUint32(frame, 3)
+	frame = append(frame, "bad"...)
+	frame = binary.BigEndian.AppendUint32(frame, 0)
+	if _, err := peer.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, h)
+	if err := h.Err(); !errors.Is(err, protocol.ErrChecksumMismatch) {
+		t.Fatalf("err = %v，期望 ErrChecksumMismatch", err)
+	}
+}
+
+// 收到对端通告后先以普通格式发送切换帧，之后的出站帧都带校验和
+func TestChecksumSwitchOutbound(t *testing.T) {
+	h, peer := newRawPeer(t, nil)
+	plain := protocol.NewDecoder(0)
+
+	if err := plain.MarshalMessage(peer, &protocol.Message{MsgID: protocol.ChecksumMsgID, Data: []byte{checksumAdvert}}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := plain.Unmarshal(peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MsgID != protocol.ChecksumMsgID || len(m.Data) != 1 || m.Data[0] != checksumSwitch {
+		t.Fatalf("帧 = %x %v，期望切换帧", m.MsgID, m.Data)
+	}
+
+	sent := make(chan error, 1)
+	go func() { sent <- h.GetConnection().SendMsg(1, []byte("crc")) }()
+	if m, err = plain.Checksum().Unmarshal(peer); err != nil {
+		t.Fatal(err)
+	}
+	if m.MsgID != 1 || string(m.Data) != "crc" {
+		t.Fatalf("帧 = %x %q", m.MsgID, m.Data)
+	}
+	if err := recv(t, sent); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 只有一端开启校验和时不切换，双方按普通格式通信
+func TestChecksumOneSided(t *testing.T) {
+	got := make(chan []byte, 1)
+	_, client := newPair(t, map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })},
+		[]Option{WithChecksum()})
+	if err := client.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+}
+
+// 双方都开启校验和时互相切换，切换前后的消息都不丢失
+func TestChecksumBothSides(t *testing.T) {
+	got := make(chan []byte, 10)
+	_, client := newPair(t, map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })},
+		[]Option{WithChecksum()}, WithChecksum())
+	for i := range 10 {
+		if err := client.GetConnection().SendMsg(1, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 10 {
+		if data := recv(t, got); len(data) != 1 || data[0] != byte(i) {
+			t.Fatalf("第%d条 data = %v", i, data)
+		}
+	}
+}
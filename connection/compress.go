@@ -0,0 +1,75 @@
+package connection
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+const DefaultCompressThreshold = 1024
+
+// advertiseCompression 向对端通告本端支持的压缩算法，按优先顺序排列
+func (h *HandlerManager) advertiseCompression() {
+	ids := make([]byte, 0, len(h.cfg.compressors))
+	for _, c := range h.cfg.compressors {
+		ids = append(ids, c.ID())
+	}
+	h.enqueueControl(&protocol.Message{MsgID: protocol.CompressMsgID, Data: ids})
+}
+
+// negotiateCompression 收到对端通告后选择双方都支持的、本端优先级最高的压缩算法
+func (h *HandlerManager) negotiateCompression(ids []byte) {
+	for _, c := range h.cfg.compressors {
+		if bytes.IndexByte(ids, c.ID()) >= 0 {
+			h.compressor.Store(&c)
+			return
+		}
+	}
+}
+
+func (h *HandlerManager) lookupCompressor(id uint8) protocol.Compressor {
+	for _, c := range h.cfg.compressors {
+		if c.ID() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// compress 按协商结果压缩出站消息
+func (h *HandlerManager) compress(message *protocol.Message) (*protocol.Message, error) {
+	c, err := h.outCompressor(message)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return message, nil
+	}
+	return protocol.Compress(message, c)
+}
+
+// outCompressor 选择出站消息使用的压缩算法，保留消息ID与小于阈值的消息不压缩，返回nil
+// 对端解压后的长度不得超过它的最大数据长度，超过时只拒绝该消息，避免对端解压失败后关闭连接
+func (h *HandlerManager) outCompressor(message *protocol.Message) (protocol.Compressor, error) {
+	c := h.compressor.Load()
+	if c == nil || message.MsgID >= protocol.ReservedMsgID {
+		return nil, nil
+	}
+	if limit := h.decompressLimit(); uint32(len(message.Data)) > limit {
+		return nil, fmt.Errorf("%w 不得大于%d", protocol.ErrDataLength, limit)
+	}
+	if len(message.Data) < h.cfg.compressThreshold {
+		return nil, nil
+	}
+	return *c, nil
+}
+
+// decompressLimit 对端解压的长度上限，握手得知对端的最大数据长度时取两者中较小的一个
+func (h *HandlerManager) decompressLimit() uint32 {
+	limit := h.maxDataLen
+	if hs := h.conn.Handshake(); hs != nil && hs.MaxDataLen > 0 {
+		limit = min(limit, hs.MaxDataLen)
+	}
+	return limit
+}
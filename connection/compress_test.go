@@ -0,0 +1,119 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// 解压后超过最大数据长度的消息在发送端被拒绝，连接不受影响
+func TestCompressRejectsOversizedMessage(t *testing.T) {
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })}
+	opt := WithCompression(10)
+	server, client := newPair(t, handler, []Option{opt}, opt)
+	eventually(t, func() bool { return client.compressor.Load() != nil })
+
+	err := client.GetConnection().SendMsg(1, make([]byte, 20000))
+	if !errors.Is(err, protocol.ErrDataLength) {
+		t.Fatalf("err = %v，期望 protocol.ErrDataLength", err)
+	}
+	if err := client.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+	select {
+	case <-server.done:
+		t.Fatalf("连接已关闭: %v", server.err)
+	default:
+	}
+}
+
+// 指定编解码器时按编解码器的最大数据长度解压
+func TestCompressUsesCodecLimit(t *testing.T) {
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })}
+	opts := []Option{WithCodec(protocol.NewVarintCodec(1 << 20)), WithCompression(10)}
+	_, client := newPair(t, handler, opts, opts...)
+	eventually(t, func() bool { return client.compressor.Load() != nil })
+
+	data := bytes.Repeat([]byte("simple-message"), 100*1024/14)
+	if err := client.GetConnection().SendMsg(1, data); err != nil {
+		t.Fatal(err)
+	}
+	if b := recv(t, got); !bytes.Equal(b, data) {
+		t.Fatalf("收到 %d 字节，期望 %d 字节", len(b), len(data))
+	}
+}
+
+// 预编码的消息同样按协商结果压缩
+func TestCompressPreparedMessage(t *testing.T) {
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })}
+	opt := WithCompression(10)
+	_, client := newPair(t, handler, []Option{opt}, opt)
+	eventually(t, func() bool { return client.compressor.Load() != nil })
+
+	data := bytes.Repeat([]byte("simple-message"), 500)
+	pm, err := NewPreparedMessage(1, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.GetConnection().SendPrepared(context.Background(), pm); err != nil {
+		t.Fatal(err)
+	}
+	if b := recv(t, got); !bytes.Equal(b, data) {
+		t.Fatalf("收到 %d 字节，期望 %d 字节", len(b), len(data))
+	}
+	f, ok := pm.frames[frameKey{codec: client.outCodec, compressor: protocol.CompressorGzip}]
+	if !ok || len(f.data) >= len(data) {
+		t.Fatal("预编码的帧未压缩")
+	}
+}
+
+// 对端发送解压后超过最大数据长度的压缩帧时以 ErrDataLength 关闭连接，防止压缩炸弹
+func TestCompressDecompressionBomb(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	h := NewHandlerManager(a, nil, 1024, func(ctx context.Context, conn *Connection) { <-ctx.Done() }, nil,
+		WithCompression(10))
+	defer func() { <-h.Stop() }()
+
+	// 读取并丢弃本端的压缩算法通告
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := b.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	message, err := protocol.Compress(&protocol.Message{MsgID: 1, Data: make([]byte, 1<<18)}, protocol.Gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Flags&protocol.FlagCompressed == 0 || len(message.Data) > 1024 {
+		t.Fatalf("压缩后 %d 字节", len(message.Data))
+	}
+	if message, err = protocol.Pack(message); err != nil {
+		t.Fatal(err)
+	}
+	var frame bytes.Buffer
+	if err := protocol.NewDecoder(0).MarshalMessage(&frame, message); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Write(frame.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, h)
+	if err := h.Err(); !errors.Is(err, protocol.ErrDataLength) {
+		t.Fatalf("err = %v，期望 protocol.ErrDataLength", err)
+	}
+}
@@ -2,26 +2,104 @@ package connection
 
 import (
 	"context"
+	"errors"
+	"net"
 	"sync"
+	"sync/atomic"
 
 	"github.com/s84662355/simple-message/protocol"
 )
 
+var connID atomic.Uint64
+
 type Connection struct {
-	msgChan  chan *MessageBody
-	ctx      context.Context
-	cancel   context.CancelFunc
-	property sync.Map
-	data     any
+	id         uint64
+	queue      *sendQueue
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+	property   sync.Map
+	data       any
+	seq        atomic.Uint32
+	pending    sync.Map
+	sendFunc   SendFunc
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	sends      inflight
+
+	streamSeq       atomic.Uint32
+	streamWriters   sync.Map
+	streamChunkSize int
+
+	handshake atomic.Pointer[Handshake]
+	identity  atomic.Pointer[identity]
+	rate      rateCounter
+
+	propagator    Propagator
+	maxHeaderSize int
 }
 
+// NewConnection 创建不绑定底层连接的 Connection，发送的消息按优先级从返回的通道中取出
+// 调用方写出后通过 MessageBody.AckMessage 确认，连接关闭后通道关闭，未取出的消息返回 ErrIsClose
 func NewConnection(data any) (*Connection, <-chan *MessageBody) {
+	C := newConnection(data, &config{})
+	msgChan := make(chan *MessageBody)
+	go func() {
+		defer close(msgChan)
+		defer C.queue.close()
+		for {
+			select {
+			case <-C.ctx.Done():
+				return
+			case <-C.queue.ready:
+			}
+			for _, m := range C.queue.pop(C.queue.size) {
+				select {
+				case msgChan <- m:
+				case <-C.ctx.Done():
+					m.ack(ErrIsClose)
+				}
+			}
+		}
+	}()
+	return C, msgChan
+}
+
+func newConnection(data any, cfg *config) *Connection {
 	C := &Connection{
-		msgChan: make(chan *MessageBody),
-		data:    data,
+		id:    connID.Add(1),
+		queue: newSendQueue(cfg.sendQueueSize, cfg.overflowPolicy, cfg.starvationLimit),
+		data:  data,
+
+		streamChunkSize: cfg.streamChunkSize,
+		propagator:      cfg.propagator,
+		maxHeaderSize:   cfg.maxHeaderSize,
+	}
+	if C.streamChunkSize <= 0 {
+		C.streamChunkSize = DefaultStreamChunkSize
+	}
+	if C.maxHeaderSize <= 0 || C.maxHeaderSize > protocol.MaxHeaderLen {
+		C.maxHeaderSize = DefaultMaxHeaderSize
 	}
-	C.ctx, C.cancel = context.WithCancel(context.Background())
-	return C, C.msgChan
+	C.sendFunc = func(ctx context.Context, conn *Connection, message *protocol.Message) error {
+		return conn.enqueue(ctx, message)
+	}
+	C.ctx, C.cancel = context.WithCancelCause(context.Background())
+	return C
+}
+
+// ID 进程内唯一的连接ID
+func (C *Connection) ID() uint64 {
+	return C.id
+}
+
+// LocalAddr 本端地址，底层连接未提供时为nil
+func (C *Connection) LocalAddr() net.Addr {
+	return C.localAddr
+}
+
+// RemoteAddr 对端地址，底层连接未提供时为nil
+func (C *Connection) RemoteAddr() net.Addr {
+	return C.remoteAddr
 }
 
 func (C *Connection) GetData() any {
@@ -68,32 +146,123 @@ func (C *Connection) SwapProperty(key, value any) (previous any, loaded bool) {
 	return C.property.Swap(key, value)
 }
 
-func (C *Connection) SendMsgContext(ctx context.Context, MsgID uint32, Data []byte) error {
-	return C.sendMsg(ctx, MsgID, Data)
+func (C *Connection) SendMsgContext(ctx context.Context, MsgID uint32, Data []byte, opts ...SendOption) error {
+	return C.sendMsg(ctx, MsgID, Data, opts)
 }
 
-func (C *Connection) SendMsg(MsgID uint32, Data []byte) error {
-	return C.sendMsg(context.TODO(), MsgID, Data)
+func (C *Connection) SendMsg(MsgID uint32, Data []byte, opts ...SendOption) error {
+	return C.sendMsg(context.TODO(), MsgID, Data, opts)
 }
 
-func (C *Connection) sendMsg(ctx context.Context, MsgID uint32, Data []byte) error {
-	m := NewMessageBody(&protocol.Message{
-		MsgID: MsgID,
-		Data:  Data,
-	})
+func (C *Connection) sendMsg(ctx context.Context, MsgID uint32, Data []byte, opts []SendOption) error {
+	if MsgID >= protocol.ReservedMsgID {
+		return ErrReservedMsgID
+	}
+	message, err := C.newMessage(MsgID, Data, opts)
+	if err != nil {
+		return err
+	}
+	return C.send(ctx, message)
+}
+
+// send 注入链路上下文后经过出站拦截器发送消息
+func (C *Connection) send(ctx context.Context, message *protocol.Message) error {
+	return C.sendFunc(ctx, C, C.inject(ctx, message))
+}
+
+// SendMsgAsync 异步发送消息，入队后立即返回，通过返回值的 Done/Err 获取发送结果
+// 队列已满且策略为 OverflowBlock 时阻塞直到入队
+func (C *Connection) SendMsgAsync(MsgID uint32, Data []byte, opts ...SendOption) *MessageBody {
+	if MsgID >= protocol.ReservedMsgID {
+		m := NewMessageBody(&protocol.Message{MsgID: MsgID, Data: Data})
+		m.ack(ErrReservedMsgID)
+		return m
+	}
+	message, err := C.newMessage(MsgID, Data, opts)
+	if err != nil {
+		m := NewMessageBody(&protocol.Message{MsgID: MsgID, Data: Data})
+		m.ack(err)
+		return m
+	}
+	holder := &asyncHolder{}
+	err = C.send(context.WithValue(context.Background(), asyncKey{}, holder), message)
+	if holder.m == nil {
+		// 被出站拦截器拦截，未入队
+		holder.m = NewMessageBody(message)
+		if err == nil {
+			err = ErrDropped
+		}
+		holder.m.ack(err)
+	}
+	return holder.m
+}
+
+type asyncKey struct{}
+
+type asyncHolder struct {
+	m *MessageBody
+}
+
+// nonBlockingKey 标记不等待队列空位的消息，队列已满且策略为 OverflowBlock 时返回 ErrQueueFull
+type nonBlockingKey struct{}
+
+// controlKey 标记框架的控制帧，不受发送队列容量与 OverflowPolicy 限制
+type controlKey struct{}
+
+// enqueue 将消息放入发送队列并等待写入完成，异步发送时入队后立即返回
+// ctx结束时返回ctx的错误，已入队的消息仍可能被发送
+func (C *Connection) enqueue(ctx context.Context, message *protocol.Message) error {
+	m := NewMessageBody(message)
+	m.priority = priorityFromContext(ctx)
+	if pm, ok := ctx.Value(preparedKey{}).(*PreparedMessage); ok && pm.message == message {
+		m.prepared = pm
+	}
+	C.sends.forceAdd()
+	m.onAck = C.sends.done
+
+	var err error
+	if ctx.Value(controlKey{}) != nil {
+		err = C.queue.pushControl(m)
+	} else {
+		err = C.queue.push(ctx, m)
+	}
+	if err != nil {
+		if errors.Is(err, ErrQueueFull) && C.queue.policy == OverflowClose {
+			C.CloseWithError(ErrQueueFull)
+		}
+		m.ack(err)
+	}
+
+	if holder, ok := ctx.Value(asyncKey{}).(*asyncHolder); ok {
+		holder.m = m
+		return nil
+	}
+
 	select {
-	case <-C.ctx.Done():
-		return ErrIsClose
 	case <-ctx.Done():
 		return ctx.Err()
-	case C.msgChan <- m:
-		<-m.ackChan
+	case <-m.Done():
 		return m.err
 	}
 }
 
+// QueueLen 发送队列中待发送的消息数量
+func (C *Connection) QueueLen() int {
+	return C.queue.Len()
+}
+
 func (C *Connection) Close() {
-	C.cancel()
+	C.cancel(ErrIsClose)
+}
+
+// CloseWithError 关闭连接，err 将作为连接错误回调的原因
+func (C *Connection) CloseWithError(err error) {
+	C.cancel(err)
+}
+
+// Err 连接关闭的原因，未关闭时为nil
+func (C *Connection) Err() error {
+	return context.Cause(C.ctx)
 }
 
 func (C *Connection) Ctx() context.Context {
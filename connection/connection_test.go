@@ -0,0 +1,30 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewConnection(t *testing.T) {
+	conn, msgChan := NewConnection(nil)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- conn.SendMsg(1, []byte("hello"))
+	}()
+	m := recv(t, msgChan)
+	if m.GetMessage().MsgID != 1 || string(m.GetMessage().Data) != "hello" {
+		t.Fatalf("收到 %+v", m.GetMessage())
+	}
+	m.AckMessage(func() error { return nil })
+	if err := recv(t, sent); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+	if _, ok := <-msgChan; ok {
+		t.Fatal("连接关闭后通道未关闭")
+	}
+	if err := conn.SendMsg(1, nil); !errors.Is(err, ErrIsClose) {
+		t.Fatalf("err = %v，期望 ErrIsClose", err)
+	}
+}
@@ -0,0 +1,170 @@
+package connection
+
+import (
+	"context"
+	"sync"
+)
+
+// DispatchMode 消息处理器的调度方式
+type DispatchMode int
+
+const (
+	DispatchInline     DispatchMode = iota // 在读协程中同步处理，处理器阻塞会阻塞整个连接的读取
+	DispatchConnQueue                      // 每个连接一个有序队列，连接内的消息按顺序处理
+	DispatchMsgIDQueue                     // 每个MsgID一个有序队列，相同MsgID的消息按顺序处理
+	DispatchPool                           // 提交到共享工作池，不保证顺序
+)
+
+const DefaultDispatchQueueSize = 128
+
+type dispatcher interface {
+	// dispatch 只在读协程中调用，队列已满时阻塞读协程形成背压
+	dispatch(msgID uint32, fn func()) error
+}
+
+func (h *HandlerManager) newDispatcher() dispatcher {
+	switch h.cfg.dispatchMode {
+	case DispatchConnQueue:
+		return h.newQueue()
+	case DispatchMsgIDQueue:
+		return &msgIDDispatcher{h: h, queues: make(map[uint32]*queueDispatcher)}
+	case DispatchPool:
+		return &poolDispatcher{ctx: h.ctx, pool: h.cfg.workerPool}
+	default:
+		return inlineDispatcher{}
+	}
+}
+
+type inlineDispatcher struct{}
+
+func (inlineDispatcher) dispatch(_ uint32, fn func()) error {
+	fn()
+	return nil
+}
+
+// queueDispatcher 单协程顺序消费的有界队列
+type queueDispatcher struct {
+	ctx   context.Context
+	tasks chan func()
+}
+
+func (h *HandlerManager) newQueue() *queueDispatcher {
+	q := &queueDispatcher{
+		ctx:   h.ctx,
+		tasks: make(chan func(), h.cfg.dispatchQueueSize),
+	}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		for {
+			select {
+			case <-q.ctx.Done():
+				return
+			case fn := <-q.tasks:
+				fn()
+			}
+		}
+	}()
+	return q
+}
+
+func (q *queueDispatcher) dispatch(_ uint32, fn func()) error {
+	select {
+	case <-q.ctx.Done():
+		return ErrIsClose
+	case q.tasks <- fn:
+		return nil
+	}
+}
+
+type msgIDDispatcher struct {
+	h      *HandlerManager
+	queues map[uint32]*queueDispatcher
+}
+
+func (d *msgIDDispatcher) dispatch(msgID uint32, fn func()) error {
+	q, ok := d.queues[msgID]
+	if !ok {
+		q = d.h.newQueue()
+		d.queues[msgID] = q
+	}
+	return q.dispatch(msgID, fn)
+}
+
+type poolDispatcher struct {
+	ctx  context.Context
+	pool *WorkerPool
+}
+
+func (d *poolDispatcher) dispatch(_ uint32, fn func()) error {
+	return d.pool.Submit(d.ctx, fn)
+}
+
+// WorkerPool 多个连接共享的有界工作池
+type WorkerPool struct {
+	tasks    chan func()
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewWorkerPool 创建工作池，workers 为工作协程数量，queueSize 为等待队列长度
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &WorkerPool{
+		tasks: make(chan func(), queueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case <-p.stop:
+					return
+				case fn := <-p.tasks:
+					fn()
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(p.done)
+		p.wg.Wait()
+	}()
+	return p
+}
+
+// Submit 提交任务，队列已满时阻塞直到有空位、ctx结束或工作池停止
+func (p *WorkerPool) Submit(ctx context.Context, fn func()) error {
+	// 先检查是否已停止，否则队列有空位时可能随机选中入队，任务不会被执行
+	select {
+	case <-p.stop:
+		return ErrIsClose
+	default:
+	}
+	select {
+	case <-p.stop:
+		return ErrIsClose
+	case <-ctx.Done():
+		return ctx.Err()
+	case p.tasks <- fn:
+		return nil
+	}
+}
+
+// Stop 停止工作池，未开始执行的任务被丢弃
+func (p *WorkerPool) Stop() <-chan struct{} {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	return p.done
+}
@@ -0,0 +1,124 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// seqHandler 记录收到的序号，全部收到后关闭done
+type seqHandler struct {
+	mu   sync.Mutex
+	seqs []uint32
+	n    int
+	done chan struct{}
+}
+
+func newSeqHandler(n int) *seqHandler {
+	return &seqHandler{n: n, done: make(chan struct{})}
+}
+
+func (s *seqHandler) Handle(r IRequest) {
+	// 前面的消息处理得慢，乱序执行时会被后面的消息超过
+	seq := binary.BigEndian.Uint32(r.GetData())
+	time.Sleep(time.Duration(s.n-int(seq)) * 100 * time.Microsecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqs = append(s.seqs, seq)
+	if len(s.seqs) == s.n {
+		close(s.done)
+	}
+}
+
+func sendSeqs(t *testing.T, conn *Connection, msgID uint32, n int) {
+	t.Helper()
+	for i := range n {
+		if err := conn.SendMsg(msgID, binary.BigEndian.AppendUint32(nil, uint32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDispatchConnQueueOrder(t *testing.T) {
+	const n = 20
+	h := newSeqHandler(2 * n)
+	_, client := newPair(t, map[uint32]Handler{1: h, 2: h}, []Option{WithDispatch(DispatchConnQueue, 4)})
+	// 两个MsgID交替发送，序号连续
+	for i := range 2 * n {
+		if err := client.GetConnection().SendMsg(uint32(1+i%2), binary.BigEndian.AppendUint32(nil, uint32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recv(t, h.done)
+	if !slices.IsSorted(h.seqs) {
+		t.Fatalf("处理顺序 %v", h.seqs)
+	}
+}
+
+func TestDispatchMsgIDQueueOrder(t *testing.T) {
+	const n = 20
+	release := make(chan struct{})
+	blocked := HandlerFunc(func(r IRequest) { <-release })
+	h := newSeqHandler(n)
+	_, client := newPair(t, map[uint32]Handler{1: blocked, 2: h}, []Option{WithDispatch(DispatchMsgIDQueue, 4)})
+	defer close(release)
+
+	// MsgID 1 的处理器阻塞时，MsgID 2 的消息照常按顺序处理
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	sendSeqs(t, client.GetConnection(), 2, n)
+	recv(t, h.done)
+	if !slices.IsSorted(h.seqs) {
+		t.Fatalf("处理顺序 %v", h.seqs)
+	}
+}
+
+func TestDispatchPool(t *testing.T) {
+	pool := NewWorkerPool(4, 8)
+	defer pool.Stop()
+	got := make(chan []byte, 1)
+	_, client := newPair(t, map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })},
+		[]Option{WithWorkerPool(pool)})
+	if err := client.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+}
+
+// 工作池的队列已满时 Submit 阻塞，停止后返回 ErrIsClose
+func TestWorkerPoolBackpressure(t *testing.T) {
+	pool := NewWorkerPool(1, 1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctx := context.Background()
+	if err := pool.Submit(ctx, func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, started)
+	if err := pool.Submit(ctx, func() {}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(timeout, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v，期望 context.DeadlineExceeded", err)
+	}
+
+	done := pool.Stop()
+	if err := pool.Submit(ctx, func() {}); !errors.Is(err, ErrIsClose) {
+		t.Fatalf("err = %v，期望 ErrIsClose", err)
+	}
+	close(release)
+	recv(t, done)
+}
@@ -0,0 +1,109 @@
+package connection
+
+import (
+	"context"
+	"sync"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// inflight 统计进行中的任务数量，进入排空状态后不再接受新任务
+type inflight struct {
+	mu       sync.Mutex
+	n        int
+	draining bool
+	idle     chan struct{}
+}
+
+// add 登记一个任务，已进入排空状态时返回false
+func (f *inflight) add() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining {
+		return false
+	}
+	f.inc()
+	return true
+}
+
+// forceAdd 登记一个任务，不受排空状态限制
+func (f *inflight) forceAdd() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inc()
+}
+
+func (f *inflight) inc() {
+	if f.n == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.n++
+}
+
+func (f *inflight) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n--
+	if f.n == 0 {
+		close(f.idle)
+	}
+}
+
+// drain 进入排空状态，返回的通道在所有任务完成后关闭
+func (f *inflight) drain() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.draining = true
+	return f.wait()
+}
+
+// idleChan 返回的通道在当前所有任务完成后关闭
+func (f *inflight) idleChan() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.wait()
+}
+
+func (f *inflight) wait() <-chan struct{} {
+	if f.n == 0 {
+		return closedChan
+	}
+	return f.idle
+}
+
+// Shutdown 优雅关闭连接: 不再处理新的请求，等待进行中的处理器完成并发送完待发送的消息后关闭
+// goingAway 为true时先通知对端连接即将关闭，ctx结束时强制关闭底层连接并立即返回ctx的错误，
+// 不等待忽略了请求ctx的处理器返回
+func (h *HandlerManager) Shutdown(ctx context.Context, goingAway bool) error {
+	handlers := h.handlers.drain()
+	if goingAway {
+		h.enqueueControl(&protocol.Message{MsgID: protocol.GoAwayMsgID})
+	}
+	if err := h.waitIdle(ctx, handlers); err != nil {
+		return err
+	}
+	// 处理器已全部完成，等待已提交的消息写入连接
+	if err := h.waitIdle(ctx, h.conn.sends.idleChan()); err != nil {
+		return err
+	}
+	<-h.Stop()
+	return nil
+}
+
+func (h *HandlerManager) waitIdle(ctx context.Context, idle <-chan struct{}) error {
+	select {
+	case <-h.ctx.Done():
+		return nil
+	case <-ctx.Done():
+		h.stop()
+		return ctx.Err()
+	case <-idle:
+		return nil
+	}
+}
@@ -1,10 +1,17 @@
 package connection
 
 import (
+	"bytes"
 	"context"
-	"maps"
+	"io"
+	"log/slog"
+	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/s84662355/simple-message/internal/logging"
+	"github.com/s84662355/simple-message/metrics"
 	"github.com/s84662355/simple-message/protocol"
 )
 
@@ -15,15 +22,35 @@ type Handler interface {
 type HandlerManager struct {
 	readWriteCloser Conn
 	conn            *Connection
-	msgChan         <-chan *MessageBody
 	handler         map[uint32]Handler
-	decoder         *protocol.Decoder
+	codec           protocol.Codec
+	inCodec         protocol.Codec // 只在读协程中访问
+	outCodec        protocol.Codec // 只在发送协程中访问
 	ctx             context.Context
 	cancel          context.CancelFunc
 	wg              sync.WaitGroup
 	err             error
 	errOnce         sync.Once
 	done            chan struct{}
+	cfg             *config
+	lastRead        atomic.Int64
+	dispatcher      dispatcher
+	handlers        inflight
+	streams         map[uint32]*streamReader // 只在读协程中访问
+	activeStreams   atomic.Int32             // 未返回的数据流处理器数量
+	maxDataLen      uint32
+	compressor      atomic.Pointer[protocol.Compressor]
+	first           *protocol.Message    // 握手时收到的旧版本对端的首个帧
+	pending         <-chan handshakeRead // 握手超时时未完成的读取
+	authState       atomic.Int32
+	authTimer       *time.Timer
+	authed          chan struct{} // 认证完成后关闭
+	rateLimiter     *rateLimiter
+	msgIDLimiters   map[uint32]*rateLimiter
+	metrics         metrics.Sink
+	logger          *slog.Logger
+	reader          io.Reader // 统计读取字节数的读取器
+	readBytes       *int
 }
 
 func NewHandlerManager(
@@ -32,26 +59,129 @@ func NewHandlerManager(
 	maxDataLen uint32,
 	connectedBegin ConnectedBegin,
 	data any,
+	opts ...Option,
 ) *HandlerManager {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if maxDataLen == 0 {
+		maxDataLen = protocol.MaxDataLen
+	}
+	if cfg.codec == nil {
+		cfg.codec = protocol.NewDecoder(maxDataLen)
+	}
+	if l, ok := cfg.codec.(protocol.DataLimiter); ok {
+		// 指定编解码器时以编解码器的限制为准，握手通告与解压都使用这个长度
+		maxDataLen = l.MaxDataLen()
+	}
+	if cfg.dispatchMode == DispatchPool && cfg.workerPool == nil {
+		cfg.dispatchMode = DispatchInline
+	}
+	if cfg.dispatchQueueSize <= 0 {
+		cfg.dispatchQueueSize = DefaultDispatchQueueSize
+	}
+	if cfg.writeBatch <= 0 {
+		cfg.writeBatch = DefaultWriteBatch
+	}
+	if cfg.maxStreamSize <= 0 {
+		cfg.maxStreamSize = DefaultMaxStreamSize
+	}
+	if cfg.maxStreams <= 0 {
+		cfg.maxStreams = DefaultMaxStreams
+	}
+
 	h := &HandlerManager{
 		readWriteCloser: readWriteCloser,
-		handler:         maps.Clone(handler),
+		handler:         make(map[uint32]Handler, len(handler)),
+
+		codec:    cfg.codec,
+		inCodec:  cfg.codec,
+		outCodec: cfg.codec,
+		done:     make(chan struct{}),
+		cfg:      cfg,
+		streams:  make(map[uint32]*streamReader),
+		authed:   make(chan struct{}),
 
-		decoder: protocol.NewDecoder(maxDataLen),
-		done:    make(chan struct{}),
+		maxDataLen: maxDataLen,
 	}
-	h.conn, h.msgChan = NewConnection(data)
+	for msgID, v := range handler {
+		h.handler[msgID] = Chain(v, cfg.middlewares...)
+	}
+	if cfg.rateLimit != nil {
+		h.rateLimiter = newRateLimiter(*cfg.rateLimit)
+	}
+	h.msgIDLimiters = make(map[uint32]*rateLimiter, len(cfg.msgIDRateLimits))
+	for msgID, limit := range cfg.msgIDRateLimits {
+		h.msgIDLimiters[msgID] = newRateLimiter(limit)
+	}
+	h.conn = newConnection(data, cfg)
+	if addr, ok := readWriteCloser.(interface{ LocalAddr() net.Addr }); ok {
+		h.conn.localAddr = addr.LocalAddr()
+	}
+	if addr, ok := readWriteCloser.(interface{ RemoteAddr() net.Addr }); ok {
+		h.conn.remoteAddr = addr.RemoteAddr()
+	}
+	h.conn.sendFunc = chainSend(h.conn.sendFunc, cfg.interceptors...)
+	h.metrics = cfg.metrics
+	if h.metrics == nil {
+		h.metrics = metrics.Discard
+	}
+	h.logger = cfg.logger
+	if h.logger == nil {
+		h.logger = logging.Discard
+	}
+	h.logger = h.logger.With(
+		slog.Uint64("conn_id", h.conn.id),
+		logging.Addr("remote_addr", h.conn.remoteAddr),
+	)
+	h.logger.Info("连接建立")
+	h.reader, h.readBytes = newCountingReader(readWriteCloser)
+	h.metrics.AddGauge(metrics.ConnectionsActive, 1)
 	h.ctx, h.cancel = context.WithCancel(context.Background())
+	h.lastRead.Store(time.Now().UnixNano())
+	h.dispatcher = h.newDispatcher()
+	invalid := cfg.validate()
+	if invalid == nil && len(cfg.compressors) > 0 {
+		h.advertiseCompression()
+	}
+	if _, ok := h.checksumCodec(); invalid == nil && ok {
+		h.enqueueControl(&protocol.Message{MsgID: protocol.ChecksumMsgID, Data: []byte{checksumAdvert}})
+	}
 
 	go func() {
-		defer close(h.done) 
+		defer close(h.done)
+		defer h.recordClose()
+		if invalid != nil {
+			h.merr(invalid)
+			h.stop()
+			h.conn.queue.close()
+			return
+		}
+		if cfg.handshakeTimeout > 0 {
+			if err := h.handshake(); err != nil {
+				h.merr(err)
+				h.stop()
+				h.conn.queue.close()
+				return
+			}
+		}
+		h.startAuth()
+		defer h.wg.Wait()
 		wg := &sync.WaitGroup{}
 		defer wg.Wait()
 		wg.Add(3)
 
 		go func() {
 			defer wg.Done()
-			connectedBegin(h.ctx, h.conn)
+			select {
+			case <-h.authed:
+			case <-h.ctx.Done():
+				return
+			}
+			h.Protect(0, func() {
+				connectedBegin(h.ctx, h.conn)
+			})
 		}()
 
 		go func() {
@@ -65,6 +195,14 @@ func NewHandlerManager(
 			defer h.stop()
 			h.send()
 		}()
+
+		if cfg.pingInterval > 0 || cfg.idleTimeout > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.heartbeat()
+			}()
+		}
 	}()
 
 	return h
@@ -89,8 +227,8 @@ func (h *HandlerManager) Err() error {
 }
 
 func (h *HandlerManager) stop() {
-    h.merr(ErrIsClose)
-	h.conn.Close()
+	h.merr(ErrIsClose)
+	h.conn.CloseWithError(h.err)
 	h.readWriteCloser.Close()
 	h.cancel()
 }
@@ -102,45 +240,180 @@ func (h *HandlerManager) merr(err error) {
 }
 
 func (h *HandlerManager) read() {
+	defer h.abortStreams(ErrIsClose)
+	if h.pending != nil {
+		if err := h.readPending(); err != nil {
+			h.merr(err)
+			return
+		}
+	}
+	if h.first != nil {
+		if err := h.process(h.first); err != nil {
+			h.merr(err)
+			return
+		}
+		h.first = nil
+	}
 	for {
-		if message, err := h.decoder.Unmarshal(h.readWriteCloser); err != nil {
+		message, err := h.inCodec.Unmarshal(h.reader)
+		h.metrics.IncCounter(metrics.BytesReceived, float64(*h.readBytes))
+		*h.readBytes = 0
+		if err != nil {
+			if cause := errorCause(err); cause != "closed" && cause != "eof" {
+				h.metrics.IncCounter(metrics.DecodeErrors, 1, causeLabel(err))
+				h.logger.Warn("解码失败", slog.String("cause", cause), slog.Any("error", err))
+			}
 			h.merr(err)
 			return
-		} else {
-			if handler, ok := h.handler[message.MsgID]; ok {
+		}
+		h.lastRead.Store(time.Now().UnixNano())
+		if err := h.process(message); err != nil {
+			h.merr(err)
+			return
+		}
+	}
+}
 
-				r := &Request{
-					conn:  h.conn,
-					data:  message.Data,
-					msgID: message.MsgID,
-				}
+// process 处理读取到的一个帧，返回错误时结束读协程
+func (h *HandlerManager) process(message *protocol.Message) error {
+	if ok, err := h.handleControl(message); err != nil || ok {
+		h.metrics.IncCounter(metrics.FramesReceived, 1, h.inboundLabel(message.MsgID))
+		return err
+	}
+
+	message, err := protocol.Unpack(message)
+	if err != nil {
+		return err
+	}
+	if message.Header.Size() > h.conn.maxHeaderSize {
+		return ErrHeaderTooLarge
+	}
+	if message, err = protocol.Decompress(message, h.maxDataLen, h.lookupCompressor); err != nil {
+		return err
+	}
+	h.metrics.IncCounter(metrics.FramesReceived, 1, h.inboundLabel(message.MsgID))
+
+	if message.Flags&protocol.FlagReply != 0 {
+		h.conn.deliverReply(message)
+		return nil
+	}
+
+	if ok, err := h.limitRate(message.MsgID, len(message.Data)); !ok {
+		return err
+	}
+
+	r := &Request{
+		conn:   h.conn,
+		data:   message.Data,
+		msgID:  message.MsgID,
+		flags:  message.Flags,
+		seq:    message.Seq,
+		ctx:    h.requestContext(message.Header),
+		header: message.Header,
+	}
 
+	if !h.allowed(message.MsgID) {
+		return h.authenticate(r)
+	}
+
+	if handler, ok := h.handler[message.MsgID]; ok {
+		if !h.handlers.add() {
+			// 优雅关闭中，不再处理新的请求
+			return nil
+		}
+		if err := h.dispatcher.dispatch(message.MsgID, func() {
+			defer h.handlers.done()
+			defer h.observeHandler(r.msgID, time.Now())
+			h.Protect(r.msgID, func() {
 				handler.Handle(r)
-			}
+			})
+		}); err != nil {
+			h.handlers.done()
+			return err
 		}
 	}
+	return nil
 }
 
 func (h *HandlerManager) send() {
-	var err error
+	defer h.conn.queue.close()
+	buf := &bytes.Buffer{}
 	for {
 		select {
 		case <-h.conn.Ctx().Done():
+			h.merr(h.conn.Err())
 			return
-		case m := <-h.msgChan:
-			m.AckMessage(func() error {
-				message := m.GetMessage()
-				err = h.decoder.Marshal(h.readWriteCloser, message.MsgID, message.Data)
-				return err
-			})
-
-			if err != nil {
-				h.merr(err)
-				return
+		case <-h.conn.queue.ready:
+			h.metrics.Observe(metrics.SendQueueDepth, float64(h.conn.queue.Len()))
+			for {
+				batch := h.conn.queue.pop(h.cfg.writeBatch)
+				if len(batch) == 0 {
+					break
+				}
+				if err := h.write(buf, batch); err != nil {
+					h.merr(err)
+					return
+				}
 			}
-
 		case <-h.ctx.Done():
 			return
 		}
 	}
 }
+
+// write 将一批消息编码后合并为一次写入，单条消息编码失败只影响该消息
+func (h *HandlerManager) write(buf *bytes.Buffer, batch []*MessageBody) error {
+	buf.Reset()
+	encoded := batch[:0]
+	for _, m := range batch {
+		n := buf.Len()
+		if err := h.encode(buf, m); err != nil {
+			buf.Truncate(n)
+			m.ack(err)
+			continue
+		}
+		encoded = append(encoded, m)
+	}
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	_, err := h.readWriteCloser.Write(buf.Bytes())
+	if err == nil {
+		h.metrics.IncCounter(metrics.BytesSent, float64(buf.Len()))
+	}
+	for _, m := range encoded {
+		if err == nil {
+			h.metrics.IncCounter(metrics.FramesSent, 1, msgIDLabel(m.GetMessage().MsgID))
+		}
+		m.ack(err)
+	}
+	return err
+}
+
+func (h *HandlerManager) encode(buf *bytes.Buffer, m *MessageBody) error {
+	if m.prepared != nil {
+		c, err := h.outCompressor(m.GetMessage())
+		if err != nil {
+			return err
+		}
+		frame, err := m.prepared.frame(h.outCodec, c)
+		if err != nil {
+			return err
+		}
+		buf.Write(frame)
+		return nil
+	}
+	message, err := h.compress(m.GetMessage())
+	if err != nil {
+		return err
+	}
+	if message, err = protocol.Pack(message); err != nil {
+		return err
+	}
+	if err := h.outCodec.MarshalMessage(buf, message); err != nil {
+		return err
+	}
+	h.switchChecksum(message)
+	return nil
+}
@@ -0,0 +1,148 @@
+package connection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+var ErrHandshake = errors.New("握手失败")
+
+// ProtocolVersion 本端实现的协议版本
+const ProtocolVersion uint16 = 1
+
+// Feature 握手时交换的特性位
+type Feature uint32
+
+const (
+	FeatureStream      Feature = 1 << iota // 数据流
+	FeatureCompression                     // 消息压缩，本端已开启 WithCompression
+	FeatureChecksum                        // 帧校验和，本端已开启 WithChecksum
+	FeatureHeader                          // 扩展帧头部
+)
+
+// Handshake 握手协商的结果
+type Handshake struct {
+	Version      uint16  // 双方协议版本中较小的一个，对端未握手时为0
+	PeerVersion  uint16  // 对端的协议版本
+	Features     Feature // 双方都支持的特性
+	PeerFeatures Feature // 对端支持的特性
+	MaxDataLen   uint32  // 对端能接收的最大数据长度，对端未握手时为0
+	Legacy       bool    // 对端是不支持握手的旧版本
+}
+
+// Has 双方是否都支持该特性
+func (hs *Handshake) Has(f Feature) bool {
+	return hs.Features&f == f
+}
+
+// hello 帧数据格式: 2字节协议版本 + 4字节特性位 + 4字节最大数据长度
+const helloLen = 2 + 4 + 4
+
+func (h *HandlerManager) localFeatures() Feature {
+	f := FeatureStream | FeatureHeader
+	if len(h.cfg.compressors) > 0 {
+		f |= FeatureCompression
+	}
+	if _, ok := h.checksumCodec(); ok {
+		f |= FeatureChecksum
+	}
+	return f
+}
+
+// handshakeRead 握手时读取首个帧的结果
+type handshakeRead struct {
+	message *protocol.Message
+	err     error
+}
+
+// handshake 在发送协程和读协程启动之前交换hello帧
+// 首个收到的帧不是hello帧时认为对端是旧版本，首个帧交给读协程按普通帧处理
+// timeout 内没有收到任何帧时同样认为对端是旧版本，不使用读超时，以免中断读取到一半的帧
+// 或损坏不支持超时后继续读取的连接(如WebSocket)，未完成的读取交给读协程继续等待
+func (h *HandlerManager) handshake() error {
+	local := h.localFeatures()
+	b := make([]byte, 0, helloLen)
+	b = binary.BigEndian.AppendUint16(b, ProtocolVersion)
+	b = binary.BigEndian.AppendUint32(b, uint32(local))
+	b = binary.BigEndian.AppendUint32(b, h.maxDataLen)
+	// 双方同时发送hello帧，写入与读取并发进行，避免无缓冲的连接互相阻塞
+	written := make(chan error, 1)
+	go func() {
+		written <- h.codec.MarshalMessage(h.readWriteCloser, &protocol.Message{MsgID: protocol.HelloMsgID, Data: b})
+	}()
+
+	read := make(chan handshakeRead, 1)
+	go func() {
+		message, err := h.codec.Unmarshal(h.reader)
+		read <- handshakeRead{message: message, err: err}
+	}()
+
+	timer := time.NewTimer(h.cfg.handshakeTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-read:
+		if r.err != nil {
+			return r.err
+		}
+		// hello帧必须在其它帧之前写入
+		if err := <-written; err != nil {
+			return err
+		}
+		h.lastRead.Store(time.Now().UnixNano())
+		if r.message.MsgID != protocol.HelloMsgID {
+			h.first = r.message
+			h.conn.handshake.Store(&Handshake{Legacy: true})
+			return nil
+		}
+		return h.acceptHello(local, r.message)
+	case <-timer.C:
+		if err := <-written; err != nil {
+			return err
+		}
+		h.pending = read
+		h.conn.handshake.Store(&Handshake{Legacy: true})
+		return nil
+	}
+}
+
+// acceptHello 按对端的hello帧保存协商结果
+func (h *HandlerManager) acceptHello(local Feature, message *protocol.Message) error {
+	if len(message.Data) < helloLen {
+		return fmt.Errorf("%w: hello帧长度%d", ErrHandshake, len(message.Data))
+	}
+	peer := Feature(binary.BigEndian.Uint32(message.Data[2:]))
+	hs := &Handshake{
+		Version:      min(ProtocolVersion, binary.BigEndian.Uint16(message.Data)),
+		PeerVersion:  binary.BigEndian.Uint16(message.Data),
+		Features:     local & peer,
+		PeerFeatures: peer,
+		MaxDataLen:   binary.BigEndian.Uint32(message.Data[6:]),
+	}
+	h.conn.handshake.Store(hs)
+	return nil
+}
+
+// readPending 读协程接管握手超时时未完成的读取
+// 对端的hello帧晚于超时到达时，按其更新协商结果，否则作为首个帧处理
+func (h *HandlerManager) readPending() error {
+	r := <-h.pending
+	h.pending = nil
+	if r.err != nil {
+		return r.err
+	}
+	h.lastRead.Store(time.Now().UnixNano())
+	if r.message.MsgID == protocol.HelloMsgID {
+		return h.acceptHello(h.localFeatures(), r.message)
+	}
+	h.first = r.message
+	return nil
+}
+
+// Handshake 握手协商的结果，未开启握手或握手尚未完成时为nil
+func (C *Connection) Handshake() *Handshake {
+	return C.handshake.Load()
+}
@@ -0,0 +1,104 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+func TestHandshake(t *testing.T) {
+	server, client := newPair(t, nil,
+		[]Option{WithHandshake(time.Second)},
+		WithHandshake(time.Second), WithChecksum(),
+	)
+	eventually(t, func() bool {
+		return server.GetConnection().Handshake() != nil && client.GetConnection().Handshake() != nil
+	})
+	hs := server.GetConnection().Handshake()
+	if hs.Legacy || hs.Version != ProtocolVersion || !hs.Has(FeatureHeader) {
+		t.Fatalf("握手结果 %+v", hs)
+	}
+	if hs.Has(FeatureChecksum) || hs.PeerFeatures&FeatureChecksum == 0 {
+		t.Fatalf("校验和特性 %+v", hs)
+	}
+}
+
+// 旧版本对端先发送普通帧，首个帧按普通帧处理
+func TestHandshakeLegacyFirstFrame(t *testing.T) {
+	got := make(chan string, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		got <- string(r.GetData())
+	})}
+	server, client := newPair(t, handler, []Option{WithHandshake(time.Second)})
+	if err := client.GetConnection().SendMsg(1, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); data != "hello" {
+		t.Fatalf("收到 %q", data)
+	}
+	if hs := server.GetConnection().Handshake(); hs == nil || !hs.Legacy {
+		t.Fatalf("握手结果 %+v，期望 Legacy", hs)
+	}
+}
+
+// 旧版本对端在超时之前只发送了半个帧，超时后剩余部分到达，帧不被破坏
+func TestHandshakeLegacyTimeoutMidFrame(t *testing.T) {
+	got := make(chan string, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		got <- string(r.GetData())
+	})}
+	a, b := net.Pipe()
+	h := NewHandlerManager(a, handler, 0, func(ctx context.Context, conn *Connection) { <-ctx.Done() }, nil,
+		WithHandshake(50*time.Millisecond))
+	defer func() { <-h.Stop() }()
+	defer b.Close()
+
+	// 读取并丢弃本端的hello帧
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := b.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	var frame bytes.Buffer
+	if err := protocol.NewDecoder(0).Marshal(&frame, 1, []byte("legacy")); err != nil {
+		t.Fatal(err)
+	}
+	raw := frame.Bytes()
+	if _, err := b.Write(raw[:3]); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return h.GetConnection().Handshake() != nil })
+	if !h.GetConnection().Handshake().Legacy {
+		t.Fatal("期望 Legacy")
+	}
+	if _, err := b.Write(raw[3:]); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); data != "legacy" {
+		t.Fatalf("收到 %q", data)
+	}
+}
+
+// 对端的hello帧晚于超时到达时更新协商结果
+func TestHandshakeLateHello(t *testing.T) {
+	a, b := net.Pipe()
+	begin := func(ctx context.Context, conn *Connection) { <-ctx.Done() }
+	server := NewHandlerManager(a, nil, 0, begin, nil, WithHandshake(20*time.Millisecond))
+	defer func() { <-server.Stop() }()
+	time.Sleep(50 * time.Millisecond)
+
+	client := NewHandlerManager(b, nil, 0, begin, nil, WithHandshake(time.Second))
+	defer func() { <-client.Stop() }()
+	eventually(t, func() bool {
+		hs := server.GetConnection().Handshake()
+		return hs != nil && !hs.Legacy && hs.Has(FeatureHeader)
+	})
+}
@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"errors"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+var (
+	ErrHeaderTooLarge    = errors.New("消息头部超过长度上限")
+	ErrHeaderUnsupported = errors.New("对端不支持消息头部")
+)
+
+// DefaultMaxHeaderSize 默认的消息头部长度上限
+const DefaultMaxHeaderSize = 8 * 1024
+
+// SendOption 发送消息的配置项
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	header protocol.Header
+}
+
+// WithHeader 为消息设置一个头部
+func WithHeader(key, value string) SendOption {
+	return func(o *sendOptions) {
+		if o.header == nil {
+			o.header = make(protocol.Header)
+		}
+		o.header[key] = value
+	}
+}
+
+// WithHeaders 为消息设置多个头部
+func WithHeaders(header protocol.Header) SendOption {
+	return func(o *sendOptions) {
+		if o.header == nil {
+			o.header = make(protocol.Header, len(header))
+		}
+		for k, v := range header {
+			o.header[k] = v
+		}
+	}
+}
+
+// newMessage 按发送配置项构造消息
+// 握手确认对端不支持头部时返回 ErrHeaderUnsupported，未握手时无法确认，照常发送
+func (C *Connection) newMessage(MsgID uint32, Data []byte, opts []SendOption) (*protocol.Message, error) {
+	message := &protocol.Message{
+		MsgID: MsgID,
+		Data:  Data,
+	}
+	if len(opts) == 0 {
+		return message, nil
+	}
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.header) == 0 {
+		return message, nil
+	}
+	if hs := C.Handshake(); hs != nil && !hs.Has(FeatureHeader) {
+		return nil, ErrHeaderUnsupported
+	}
+	if err := C.checkHeader(o.header); err != nil {
+		return nil, err
+	}
+	message.Header = o.header
+	return message, nil
+}
+
+// checkHeader 检查头部的键值长度与总长度
+func (C *Connection) checkHeader(header protocol.Header) error {
+	for k, v := range header {
+		if len(k) == 0 || len(k) > protocol.MaxHeaderKeyLen || len(v) > protocol.MaxHeaderValueLen {
+			return protocol.ErrHeader
+		}
+	}
+	if header.Size() > C.maxHeaderSize {
+		return ErrHeaderTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package connection
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeader(t *testing.T) {
+	got := make(chan IRequest, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r })}
+	_, client := newPair(t, handler, nil)
+
+	if err := client.GetConnection().SendMsg(1, []byte("hi"), WithHeader("a", "1"), WithHeader("b", "")); err != nil {
+		t.Fatal(err)
+	}
+	r := recv(t, got)
+	if string(r.GetData()) != "hi" || r.GetHeader("a") != "1" || len(r.GetHeaders()) != 2 {
+		t.Fatalf("收到 %q，头部 %v", r.GetData(), r.GetHeaders())
+	}
+}
+
+// 发送超过上限的头部返回错误，连接不受影响
+func TestHeaderTooLargeSend(t *testing.T) {
+	server, client := newPair(t, nil, nil, WithMaxHeaderSize(16))
+
+	err := client.GetConnection().SendMsg(1, nil, WithHeader("key", strings.Repeat("v", 16)))
+	if !errors.Is(err, ErrHeaderTooLarge) {
+		t.Fatalf("err = %v，期望 ErrHeaderTooLarge", err)
+	}
+	if err := client.GetConnection().SendMsg(1, nil, WithHeader("key", "v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.GetConnection().Err(); err != nil {
+		t.Fatalf("连接被关闭: %v", err)
+	}
+}
+
+// 收到超过上限的头部时关闭连接
+func TestHeaderTooLargeReceive(t *testing.T) {
+	server, client := newPair(t, nil, []Option{WithMaxHeaderSize(16)})
+
+	if err := client.GetConnection().SendMsg(1, nil, WithHeader("key", strings.Repeat("v", 16))); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, server)
+	if err := server.Err(); !errors.Is(err, ErrHeaderTooLarge) {
+		t.Fatalf("err = %v，期望 ErrHeaderTooLarge", err)
+	}
+}
+
+// 握手确认对端是旧版本后，带头部的消息返回 ErrHeaderUnsupported
+func TestHeaderUnsupported(t *testing.T) {
+	_, client := newPair(t, nil, nil, WithHandshake(20*time.Millisecond))
+	eventually(t, func() bool { return client.GetConnection().Handshake() != nil })
+	if !client.GetConnection().Handshake().Legacy {
+		t.Fatal("期望 Legacy")
+	}
+
+	err := client.GetConnection().SendMsg(1, nil, WithHeader("key", "v"))
+	if !errors.Is(err, ErrHeaderUnsupported) {
+		t.Fatalf("err = %v，期望 ErrHeaderUnsupported", err)
+	}
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+}
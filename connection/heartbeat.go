@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"context"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// heartbeat 定时发送心跳并检测空闲超时，两者使用各自的定时器，空闲超时不受心跳间隔影响
+func (h *HandlerManager) heartbeat() {
+	var ping, idle <-chan time.Time
+	if h.cfg.pingInterval > 0 {
+		ticker := time.NewTicker(h.cfg.pingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	var timer *time.Timer
+	if h.cfg.idleTimeout > 0 {
+		timer = time.NewTimer(h.cfg.idleTimeout)
+		defer timer.Stop()
+		idle = timer.C
+	}
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ping:
+			// 不等待写入完成，写入阻塞时仍能按时检测空闲超时
+			h.enqueueControl(&protocol.Message{MsgID: protocol.PingMsgID})
+		case now := <-idle:
+			elapsed := now.Sub(time.Unix(0, h.lastRead.Load()))
+			if elapsed >= h.cfg.idleTimeout {
+				h.merr(ErrIdleTimeout)
+				h.stop()
+				return
+			}
+			// 在最后一次读取之后的 idleTimeout 时再检查
+			timer.Reset(h.cfg.idleTimeout - elapsed)
+		}
+	}
+}
+
+// handleControl 处理框架内部的控制帧，返回false表示不是控制帧
+func (h *HandlerManager) handleControl(message *protocol.Message) (bool, error) {
+	switch message.MsgID {
+	case protocol.StreamMsgID:
+		// 数据流帧按目标MsgID计入速率限制
+		return true, h.handleStream(message.Data)
+	case protocol.PingMsgID, protocol.PongMsgID, protocol.GoAwayMsgID,
+		protocol.CompressMsgID, protocol.ChecksumMsgID, protocol.HelloMsgID:
+	default:
+		return false, nil
+	}
+	// 控制帧计入连接的速率限制，只丢弃心跳，其它控制帧会改变连接状态
+	if ok, err := h.limitRate(message.MsgID, len(message.Data)); !ok {
+		if err != nil || message.MsgID == protocol.PingMsgID {
+			return true, err
+		}
+	}
+
+	switch message.MsgID {
+	case protocol.PingMsgID:
+		h.enqueueControl(&protocol.Message{MsgID: protocol.PongMsgID})
+	case protocol.PongMsgID:
+	case protocol.GoAwayMsgID:
+		h.merr(ErrGoingAway)
+	case protocol.CompressMsgID:
+		h.negotiateCompression(message.Data)
+	case protocol.ChecksumMsgID:
+		return true, h.handleChecksum(message.Data)
+	case protocol.HelloMsgID:
+		// 未开启握手时忽略对端的hello帧
+	}
+	return true, nil
+}
+
+// enqueueControl 先于其它消息发送控制帧，不受发送队列容量限制，不等待写入完成
+func (h *HandlerManager) enqueueControl(message *protocol.Message) {
+	ctx := context.WithValue(ContextWithPriority(h.ctx, PriorityHigh), asyncKey{}, &asyncHolder{})
+	ctx = context.WithValue(ctx, controlKey{}, true)
+	h.conn.enqueue(ctx, message)
+}
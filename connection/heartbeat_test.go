@@ -0,0 +1,52 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 对端不读取数据导致写入阻塞时，仍能按时以空闲超时关闭连接
+func TestIdleTimeoutWhileWriteBlocked(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	h := NewHandlerManager(a, nil, 0, func(ctx context.Context, conn *Connection) {}, nil,
+		WithHeartbeat(10*time.Millisecond, 100*time.Millisecond))
+	waitDone(t, h)
+	if err := h.Err(); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("err = %v，期望 ErrIdleTimeout", err)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	server, client := newPair(t, nil,
+		[]Option{WithHeartbeat(0, 200*time.Millisecond)},
+		WithHeartbeat(20*time.Millisecond, 0),
+	)
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case <-server.Ctx().Done():
+		t.Fatalf("收到心跳的连接被关闭: %v", server.Err())
+	case <-client.Ctx().Done():
+		t.Fatalf("连接被关闭: %v", client.Err())
+	default:
+	}
+}
+
+// 空闲超时小于心跳间隔时按空闲超时关闭连接
+func TestIdleTimeoutShorterThanPing(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	start := time.Now()
+	h := NewHandlerManager(a, nil, 0, func(ctx context.Context, conn *Connection) {}, nil,
+		WithHeartbeat(2*time.Second, 100*time.Millisecond))
+	waitDone(t, h)
+	if err := h.Err(); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("err = %v，期望 ErrIdleTimeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("%v 后才关闭连接", d)
+	}
+}
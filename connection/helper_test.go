@@ -0,0 +1,56 @@
+package connection
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// newPair 通过 net.Pipe 建立一对连接，返回服务端与客户端
+func newPair(t *testing.T, handler map[uint32]Handler, serverOpts []Option, clientOpts ...Option) (*HandlerManager, *HandlerManager) {
+	t.Helper()
+	a, b := net.Pipe()
+	begin := func(ctx context.Context, conn *Connection) { <-ctx.Done() }
+	server := NewHandlerManager(a, handler, 0, begin, nil, serverOpts...)
+	client := NewHandlerManager(b, nil, 0, begin, nil, clientOpts...)
+	t.Cleanup(func() {
+		<-client.Stop()
+		<-server.Stop()
+	})
+	return server, client
+}
+
+// waitDone 等待连接结束，超时则失败
+func waitDone(t *testing.T, h *HandlerManager) {
+	t.Helper()
+	select {
+	case <-h.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("连接未结束")
+	}
+}
+
+// eventually 轮询直到cond成立，超时则失败
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("条件未满足")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// recv 从通道接收一个值，超时则失败
+func recv[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待超时")
+		panic("unreachable")
+	}
+}
@@ -9,16 +9,20 @@ import (
 type T func() error
 
 type MessageBody struct {
-	message *protocol.Message
-	err     error
-	ackChan chan struct{}
-	status  atomic.Bool
+	message  *protocol.Message
+	prepared *PreparedMessage
+	err      error
+	ackChan  chan struct{}
+	status   atomic.Bool
+	onAck    func()
+	priority Priority
 }
 
 func NewMessageBody(message *protocol.Message) *MessageBody {
 	return &MessageBody{
-		message: message,
-		ackChan: make(chan struct{}),
+		message:  message,
+		ackChan:  make(chan struct{}),
+		priority: PriorityNormal,
 	}
 }
 
@@ -30,9 +34,23 @@ func (m *MessageBody) Done() <-chan struct{} {
 	return m.ackChan
 }
 
+// Err 发送结果，Done 关闭后有效
+func (m *MessageBody) Err() error {
+	return m.err
+}
+
 func (m *MessageBody) AckMessage(t T) {
 	if m.status.CompareAndSwap(false, true) {
 		m.err = t()
 		close(m.ackChan)
+		if m.onAck != nil {
+			m.onAck()
+		}
 	}
 }
+
+func (m *MessageBody) ack(err error) {
+	m.AckMessage(func() error {
+		return err
+	})
+}
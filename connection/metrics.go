@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/s84662355/simple-message/metrics"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// countingReader 统计读取的字节数，只在读协程中使用
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// countingMessageReader 保留底层连接按消息读取的能力，供基于消息的编解码器使用
+type countingMessageReader struct {
+	countingReader
+	m interface{ ReadMessage() ([]byte, error) }
+}
+
+func (c *countingMessageReader) ReadMessage() ([]byte, error) {
+	b, err := c.m.ReadMessage()
+	c.n += len(b)
+	return b, err
+}
+
+func newCountingReader(r io.Reader) (io.Reader, *int) {
+	if m, ok := r.(interface{ ReadMessage() ([]byte, error) }); ok {
+		c := &countingMessageReader{countingReader: countingReader{r: r}, m: m}
+		return c, &c.n
+	}
+	c := &countingReader{r: r}
+	return c, &c.n
+}
+
+func msgIDLabel(msgID uint32) metrics.Label {
+	return metrics.Label{Name: "msg_id", Value: strconv.FormatUint(uint64(msgID), 10)}
+}
+
+func causeLabel(err error) metrics.Label {
+	return metrics.Label{Name: "cause", Value: errorCause(err)}
+}
+
+// errorCause 将错误归类为有限的几种原因，用作指标标签
+func errorCause(err error) string {
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, ErrIsClose), errors.Is(err, net.ErrClosed):
+		return "closed"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	case errors.Is(err, ErrIdleTimeout):
+		return "idle_timeout"
+	case errors.Is(err, ErrGoingAway):
+		return "going_away"
+	case errors.Is(err, protocol.ErrChecksumMismatch):
+		return "checksum"
+	case errors.Is(err, protocol.ErrDataLength):
+		return "data_length"
+	case errors.Is(err, protocol.ErrExtFrame), errors.Is(err, protocol.ErrCompressor):
+		return "bad_frame"
+	case errors.Is(err, ErrHandshake):
+		return "handshake"
+	case errors.Is(err, ErrAuthFailed), errors.Is(err, ErrAuthTimeout):
+		return "auth"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrQueueFull):
+		return "queue_full"
+	case errors.Is(err, ErrHandlerPanic):
+		return "panic"
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	}
+	return "other"
+}
+
+// recordClose 记录连接关闭及原因
+func (h *HandlerManager) recordClose() {
+	h.metrics.AddGauge(metrics.ConnectionsActive, -1)
+	h.metrics.IncCounter(metrics.ConnectionsClosed, 1, causeLabel(h.err))
+	level := slog.LevelInfo
+	if cause := errorCause(h.err); cause != "closed" && cause != "eof" {
+		level = slog.LevelWarn
+	}
+	h.logger.Log(context.Background(), level, "连接断开", slog.String("cause", errorCause(h.err)), slog.Any("error", h.err))
+}
+
+// inboundLabel 入站帧的 msg_id 标签，只有注册了处理器的消息与保留的控制帧使用MsgID，其余归为other，
+// 以免对端发送任意MsgID制造无限多的指标序列
+func (h *HandlerManager) inboundLabel(msgID uint32) metrics.Label {
+	if _, ok := h.handler[msgID]; ok || msgID >= protocol.ReservedMsgID {
+		return msgIDLabel(msgID)
+	}
+	return metrics.Label{Name: "msg_id", Value: "other"}
+}
+
+// observeHandler 记录处理器耗时
+func (h *HandlerManager) observeHandler(msgID uint32, start time.Time) {
+	h.metrics.Observe(metrics.HandlerDuration, time.Since(start).Seconds(), msgIDLabel(msgID))
+}
@@ -0,0 +1,65 @@
+package connection
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/s84662355/simple-message/metrics"
+)
+
+// 未注册处理器的MsgID不产生独立的指标序列
+func TestFramesReceivedLabel(t *testing.T) {
+	got := make(chan struct{}, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		got <- struct{}{}
+	})}
+	reg := metrics.NewRegistry()
+	_, client := newPair(t, handler, []Option{WithMetrics(reg)})
+
+	conn := client.GetConnection()
+	for msgID := uint32(100); msgID < 110; msgID++ {
+		if err := conn.SendMsg(msgID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conn.SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, got)
+
+	var out strings.Builder
+	reg.WriteTo(&out)
+	text := out.String()
+	if !strings.Contains(text, `msg_id="1"`) || !strings.Contains(text, `msg_id="other"`) {
+		t.Fatalf("缺少预期的标签:\n%s", text)
+	}
+	if strings.Contains(text, `msg_id="100"`) {
+		t.Fatalf("未注册的MsgID产生了指标序列:\n%s", text)
+	}
+}
+
+// 数据流处理器的耗时同样记录到 HandlerDuration
+func TestStreamHandlerDuration(t *testing.T) {
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		io.Copy(io.Discard, r.GetReader())
+	})}
+	reg := metrics.NewRegistry()
+	_, client := newPair(t, handler, []Option{WithMetrics(reg)})
+
+	w, err := client.GetConnection().OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool {
+		var out strings.Builder
+		reg.WriteTo(&out)
+		return strings.Contains(out.String(), metrics.HandlerDuration+`_count{msg_id="1"} 1`)
+	})
+}
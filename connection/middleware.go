@@ -0,0 +1,38 @@
+package connection
+
+import (
+	"context"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// HandlerFunc 函数形式的消息处理器
+type HandlerFunc func(request IRequest)
+
+func (f HandlerFunc) Handle(request IRequest) {
+	f(request)
+}
+
+// Middleware 消息处理器中间件，可用于鉴权、日志、监控等横切逻辑
+type Middleware func(next Handler) Handler
+
+// Chain 用中间件包装处理器，第一个中间件位于最外层
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// SendFunc 发送消息的函数
+type SendFunc func(ctx context.Context, conn *Connection, message *protocol.Message) error
+
+// SendInterceptor 出站拦截器，包装业务消息、请求与应答的发送，不包含心跳等控制帧
+type SendInterceptor func(next SendFunc) SendFunc
+
+func chainSend(send SendFunc, interceptors ...SendInterceptor) SendFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		send = interceptors[i](send)
+	}
+	return send
+}
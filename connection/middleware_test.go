@@ -0,0 +1,100 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// 第一个中间件位于最外层
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(r IRequest) {
+				calls = append(calls, name+" 前")
+				next.Handle(r)
+				calls = append(calls, name+" 后")
+			})
+		}
+	}
+	h := Chain(HandlerFunc(func(r IRequest) { calls = append(calls, "处理器") }), record("a"), record("b"))
+	h.Handle(&Request{})
+	want := []string{"a 前", "b 前", "处理器", "b 后", "a 后"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("调用顺序 %v，期望 %v", calls, want)
+	}
+}
+
+// 中间件可以拦截消息，不调用next时处理器不执行
+func TestMiddlewareIntercept(t *testing.T) {
+	got := make(chan uint32, 2)
+	deny := func(next Handler) Handler {
+		return HandlerFunc(func(r IRequest) {
+			if r.GetMsgID() == 1 {
+				return
+			}
+			next.Handle(r)
+		})
+	}
+	record := HandlerFunc(func(r IRequest) { got <- r.GetMsgID() })
+	_, client := newPair(t, map[uint32]Handler{1: record, 2: record}, []Option{WithMiddleware(deny)})
+	client.GetConnection().SendMsg(1, nil)
+	client.GetConnection().SendMsg(2, nil)
+	if msgID := recv(t, got); msgID != 2 {
+		t.Fatalf("msgID = %d，期望 2", msgID)
+	}
+}
+
+func TestSendInterceptorReplace(t *testing.T) {
+	got := make(chan []byte, 1)
+	replace := func(next SendFunc) SendFunc {
+		return func(ctx context.Context, conn *Connection, message *protocol.Message) error {
+			m := *message
+			m.Data = append([]byte("替换:"), message.Data...)
+			return next(ctx, conn, &m)
+		}
+	}
+	_, client := newPair(t, map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })}, nil,
+		WithSendInterceptor(replace))
+	if err := client.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "替换:hi" {
+		t.Fatalf("data = %q", data)
+	}
+}
+
+// 拦截器不调用next时消息不发送，SendMsgAsync 返回 ErrDropped
+func TestSendInterceptorDrop(t *testing.T) {
+	got := make(chan uint32, 2)
+	drop := func(next SendFunc) SendFunc {
+		return func(ctx context.Context, conn *Connection, message *protocol.Message) error {
+			if message.MsgID == 1 {
+				return nil
+			}
+			return next(ctx, conn, message)
+		}
+	}
+	record := HandlerFunc(func(r IRequest) { got <- r.GetMsgID() })
+	_, client := newPair(t, map[uint32]Handler{1: record, 2: record}, nil, WithSendInterceptor(drop))
+
+	conn := client.GetConnection()
+	if err := conn.SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	m := conn.SendMsgAsync(1, nil)
+	<-m.Done()
+	if err := m.Err(); !errors.Is(err, ErrDropped) {
+		t.Fatalf("err = %v，期望 ErrDropped", err)
+	}
+	if err := conn.SendMsg(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if msgID := recv(t, got); msgID != 2 {
+		t.Fatalf("msgID = %d，期望 2", msgID)
+	}
+}
@@ -0,0 +1,307 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/s84662355/simple-message/metrics"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+type config struct {
+	codec        protocol.Codec
+	pingInterval time.Duration
+	idleTimeout  time.Duration
+
+	dispatchMode      DispatchMode
+	dispatchQueueSize int
+	workerPool        *WorkerPool
+
+	middlewares  []Middleware
+	interceptors []SendInterceptor
+
+	panicHook   PanicHook
+	panicPolicy PanicPolicy
+
+	sendQueueSize  int
+	overflowPolicy OverflowPolicy
+	writeBatch     int
+
+	starvationLimit int
+
+	streamChunkSize int
+	maxStreamSize   int64
+	maxStreams      int
+
+	compressors       []protocol.Compressor
+	compressThreshold int
+
+	checksum bool
+
+	handshakeTimeout time.Duration
+
+	authenticator Authenticator
+	authTimeout   time.Duration
+	authWhitelist map[uint32]struct{}
+
+	rateLimit       *RateLimit
+	msgIDRateLimits map[uint32]RateLimit
+	ratePolicy      RatePolicy
+
+	metrics metrics.Sink
+
+	propagator Propagator
+
+	maxHeaderSize int
+
+	logger *slog.Logger
+}
+
+// ErrCodecIncompatible 编解码器不支持开启的配置项，连接建立后立即以该错误关闭
+var ErrCodecIncompatible = errors.New("编解码器不支持该配置")
+
+// Option 连接配置项
+type Option func(*config)
+
+// validate 检查编解码器能否支持开启的配置项，避免之后每个控制帧都编码失败
+func (c *config) validate() error {
+	if c.checksum {
+		if _, ok := c.codec.(protocol.ChecksumCodec); !ok {
+			return fmt.Errorf("%w: WithChecksum 需要编解码器实现 protocol.ChecksumCodec", ErrCodecIncompatible)
+		}
+	}
+	if protocol.SupportsReserved(c.codec) {
+		return nil
+	}
+	var opt string
+	switch {
+	case c.pingInterval > 0:
+		opt = "WithHeartbeat"
+	case c.handshakeTimeout > 0:
+		opt = "WithHandshake"
+	case len(c.compressors) > 0:
+		opt = "WithCompression"
+	case c.checksum:
+		opt = "WithChecksum"
+	case c.propagator != nil:
+		opt = "WithPropagator"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s 需要编解码器支持保留消息ID", ErrCodecIncompatible, opt)
+}
+
+// WithCodec 指定消息编解码器，不指定时使用 protocol.NewDecoder(maxDataLen)
+func WithCodec(codec protocol.Codec) Option {
+	return func(c *config) {
+		c.codec = codec
+	}
+}
+
+// WithHeartbeat 开启心跳检测
+// pingInterval 大于0时按该间隔向对端发送心跳帧，对端自动应答
+// idleTimeout 大于0时超过该时长未收到任何帧则以 ErrIdleTimeout 关闭连接
+// 心跳帧使用保留消息ID，要求编解码器支持32位MsgID
+func WithHeartbeat(pingInterval, idleTimeout time.Duration) Option {
+	return func(c *config) {
+		c.pingInterval = pingInterval
+		c.idleTimeout = idleTimeout
+	}
+}
+
+// WithDispatch 指定消息处理器的调度方式，queueSize 为有序队列的长度，队列满时阻塞读取
+// DispatchPool 需配合 WithWorkerPool 使用
+func WithDispatch(mode DispatchMode, queueSize int) Option {
+	return func(c *config) {
+		c.dispatchMode = mode
+		c.dispatchQueueSize = queueSize
+	}
+}
+
+// WithWorkerPool 将消息处理器提交到共享工作池执行
+func WithWorkerPool(pool *WorkerPool) Option {
+	return func(c *config) {
+		c.dispatchMode = DispatchPool
+		c.workerPool = pool
+	}
+}
+
+// WithMiddleware 追加消息处理器中间件
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithSendInterceptor 追加出站拦截器
+func WithSendInterceptor(interceptors ...SendInterceptor) Option {
+	return func(c *config) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// WithPanicHook 处理器或连接回调发生panic时的回调
+func WithPanicHook(hook PanicHook) Option {
+	return func(c *config) {
+		c.panicHook = hook
+	}
+}
+
+// WithPanicPolicy 发生panic后的处理策略，默认 PanicCloseConn
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(c *config) {
+		c.panicPolicy = policy
+	}
+}
+
+// WithSendQueue 指定发送队列长度及队列已满时的处理策略，默认长度 DefaultSendQueueSize，策略 OverflowBlock
+func WithSendQueue(size int, policy OverflowPolicy) Option {
+	return func(c *config) {
+		c.sendQueueSize = size
+		c.overflowPolicy = policy
+	}
+}
+
+// WithWriteBatch 每次写入连接时最多合并的消息数量，1表示不合并，默认 DefaultWriteBatch
+func WithWriteBatch(n int) Option {
+	return func(c *config) {
+		c.writeBatch = n
+	}
+}
+
+// WithStarvationLimit 低优先级消息最多连续让出的次数，默认 DefaultStarvationLimit
+func WithStarvationLimit(n int) Option {
+	return func(c *config) {
+		c.starvationLimit = n
+	}
+}
+
+// WithStreamChunkSize 数据流切分的数据块大小，默认 DefaultStreamChunkSize
+// 数据块加上帧头不得超过对端的 maxDataLen
+func WithStreamChunkSize(size int) Option {
+	return func(c *config) {
+		c.streamChunkSize = size
+	}
+}
+
+// WithMaxStreamSize 接收单个数据流的最大字节数，超过时重置该数据流，默认 DefaultMaxStreamSize
+func WithMaxStreamSize(size int64) Option {
+	return func(c *config) {
+		c.maxStreamSize = size
+	}
+}
+
+// WithMaxStreams 同时处理的对端数据流数量上限，超过时重置新打开的数据流，默认 DefaultMaxStreams
+func WithMaxStreams(n int) Option {
+	return func(c *config) {
+		c.maxStreams = n
+	}
+}
+
+// WithCompression 开启消息数据压缩，compressors 按优先顺序排列，为空时使用 protocol.Gzip
+// 连接建立后双方互相通告支持的算法，双方都开启时才压缩，数据长度小于 threshold 的消息不压缩
+// 数据长度超过对端解压上限(编解码器的最大数据长度)的消息发送时返回 protocol.ErrDataLength
+func WithCompression(threshold int, compressors ...protocol.Compressor) Option {
+	return func(c *config) {
+		if len(compressors) == 0 {
+			compressors = []protocol.Compressor{protocol.Gzip}
+		}
+		c.compressThreshold = threshold
+		c.compressors = compressors
+	}
+}
+
+// WithChecksum 开启帧校验和，要求编解码器实现 protocol.ChecksumCodec
+// 双方都开启时各自在通告后切换为带CRC32C校验和的帧，校验失败以 protocol.ErrChecksumMismatch 关闭连接
+// 未开启的旧版本对端忽略通告，继续使用不带校验和的帧
+func WithChecksum() Option {
+	return func(c *config) {
+		c.checksum = true
+	}
+}
+
+// WithHandshake 开启握手，在 ConnectedBegin 之前交换协议版本、支持的特性和最大数据长度
+// 结果通过 Connection.Handshake 获取，对端是不支持握手的旧版本时 Handshake.Legacy 为true
+// timeout 内未收到对端的帧时按旧版本处理，之后收到的hello帧仍会更新协商结果
+func WithHandshake(timeout time.Duration) Option {
+	return func(c *config) {
+		c.handshakeTimeout = timeout
+	}
+}
+
+// WithAuthenticator 开启认证，认证完成后才调用 ConnectedBegin
+// timeout 内未完成认证则以 ErrAuthTimeout 关闭连接，小于等于0时使用 DefaultAuthTimeout
+// 认证完成前只有 whitelist 中的消息会分发给处理器，其它消息交给认证器
+func WithAuthenticator(authenticator Authenticator, timeout time.Duration, whitelist ...uint32) Option {
+	return func(c *config) {
+		if timeout <= 0 {
+			timeout = DefaultAuthTimeout
+		}
+		c.authenticator = authenticator
+		c.authTimeout = timeout
+		c.authWhitelist = make(map[uint32]struct{}, len(whitelist))
+		for _, msgID := range whitelist {
+			c.authWhitelist[msgID] = struct{}{}
+		}
+	}
+}
+
+// WithRateLimit 限制本连接入站消息的速率，超过时按 policy 处理
+// 分发给处理器或认证器的消息、数据流的数据块与控制帧都计入限制，应答不计入
+func WithRateLimit(limit RateLimit, policy RatePolicy) Option {
+	return func(c *config) {
+		c.rateLimit = &limit
+		c.ratePolicy = policy
+	}
+}
+
+// WithMsgIDRateLimit 限制指定MsgID入站消息的速率，与连接级别的限制同时生效
+// 超过时的处理策略由 WithRatePolicy 指定，默认 RateDrop
+func WithMsgIDRateLimit(msgID uint32, limit RateLimit) Option {
+	return func(c *config) {
+		if c.msgIDRateLimits == nil {
+			c.msgIDRateLimits = make(map[uint32]RateLimit)
+		}
+		c.msgIDRateLimits[msgID] = limit
+	}
+}
+
+// WithRatePolicy 入站消息超过速率限制时的处理策略
+func WithRatePolicy(policy RatePolicy) Option {
+	return func(c *config) {
+		c.ratePolicy = policy
+	}
+}
+
+// WithMetrics 将连接的指标记录到sink，如 metrics.NewRegistry()
+func WithMetrics(sink metrics.Sink) Option {
+	return func(c *config) {
+		c.metrics = sink
+	}
+}
+
+// WithPropagator 开启链路上下文传递，发送消息时从ctx注入到消息头部，处理器通过 IRequest.Context 获取
+// 不使用 OpenTelemetry 时传入 W3CPropagator{}，配合 ContextWithTrace 使用
+// 头部只有新版本的对端能解析，对端可能是旧版本时需同时开启 WithHandshake
+func WithPropagator(propagator Propagator) Option {
+	return func(c *config) {
+		c.propagator = propagator
+	}
+}
+
+// WithMaxHeaderSize 指定消息头部编码后的长度上限，默认 DefaultMaxHeaderSize
+// 发送超过上限的头部返回 ErrHeaderTooLarge，收到超过上限的头部时关闭连接
+func WithMaxHeaderSize(size int) Option {
+	return func(c *config) {
+		c.maxHeaderSize = size
+	}
+}
+
+// WithLogger 将连接建立与断开、解码错误、处理器panic输出到logger，日志带有 conn_id 与 remote_addr 属性
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
@@ -0,0 +1,40 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+func TestShortIDCodecRejectsReservedOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"WithHeartbeat":   WithHeartbeat(time.Second, 0),
+		"WithHandshake":   WithHandshake(time.Second),
+		"WithCompression": WithCompression(0, protocol.Gzip),
+		"WithChecksum":    WithChecksum(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := WithCodec(protocol.NewShortIDCodec(0))
+			server, _ := newPair(t, nil, []Option{codec, opt}, codec)
+			waitDone(t, server)
+			if err := server.Err(); !errors.Is(err, ErrCodecIncompatible) {
+				t.Fatalf("err = %v，期望 ErrCodecIncompatible", err)
+			}
+		})
+	}
+}
+
+func TestShortIDCodecPlainMessages(t *testing.T) {
+	got := make(chan []byte, 1)
+	codec := WithCodec(protocol.NewShortIDCodec(0))
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r.GetData() })}
+	_, client := newPair(t, handler, []Option{codec}, codec)
+	if err := client.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+}
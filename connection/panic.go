@@ -0,0 +1,64 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+var ErrHandlerPanic = errors.New("处理器发生panic")
+
+// HandlerPanicError 消息处理器或连接回调中发生的panic
+type HandlerPanicError struct {
+	MsgID uint32 // 发生panic的消息ID，连接回调中发生时为0
+	Value any    // recover得到的值
+	Stack []byte // 发生panic时的调用栈
+}
+
+func (e *HandlerPanicError) Error() string {
+	return fmt.Sprintf("%v msgID=%d: %v", ErrHandlerPanic, e.MsgID, e.Value)
+}
+
+func (e *HandlerPanicError) Unwrap() error {
+	return ErrHandlerPanic
+}
+
+// PanicPolicy 发生panic后的处理策略
+type PanicPolicy int
+
+const (
+	PanicCloseConn   PanicPolicy = iota // 以 *HandlerPanicError 关闭发生panic的连接
+	PanicDropMessage                    // 丢弃当前消息，连接继续处理后续消息
+)
+
+// PanicHook panic发生后的回调
+type PanicHook func(conn *Connection, err *HandlerPanicError)
+
+// Protect 执行fn并捕获其中的panic，发生panic时回调 PanicHook 并按 PanicPolicy 处理，返回是否正常执行完成
+func (h *HandlerManager) Protect(msgID uint32, fn func()) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			ok = false
+			err := &HandlerPanicError{
+				MsgID: msgID,
+				Value: v,
+				Stack: debug.Stack(),
+			}
+			h.logger.Error("处理器panic",
+				slog.Uint64("msg_id", uint64(msgID)),
+				slog.Any("panic", v),
+				slog.String("stack", string(err.Stack)),
+			)
+			if h.cfg.panicHook != nil {
+				h.cfg.panicHook(h.conn, err)
+			}
+			if h.cfg.panicPolicy == PanicCloseConn {
+				h.merr(err)
+				h.stop()
+			}
+		}
+	}()
+	fn()
+	return true
+}
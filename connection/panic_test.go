@@ -0,0 +1,76 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPanicDropMessage(t *testing.T) {
+	hooked := make(chan *HandlerPanicError, 1)
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{
+		1: HandlerFunc(func(r IRequest) { panic("boom") }),
+		2: HandlerFunc(func(r IRequest) { got <- r.GetData() }),
+	}
+	server, client := newPair(t, handler, []Option{
+		WithPanicPolicy(PanicDropMessage),
+		WithPanicHook(func(conn *Connection, err *HandlerPanicError) { hooked <- err }),
+	})
+
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv(t, hooked); err.MsgID != 1 || err.Value != "boom" {
+		t.Fatalf("hook 收到 %+v", err)
+	}
+	// 连接继续处理后续消息
+	if err := client.GetConnection().SendMsg(2, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+	if err := server.GetConnection().Err(); err != nil {
+		t.Fatalf("连接被关闭: %v", err)
+	}
+}
+
+func TestPanicCloseConn(t *testing.T) {
+	hooked := make(chan *HandlerPanicError, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { panic("boom") })}
+	server, client := newPair(t, handler, []Option{
+		WithPanicHook(func(conn *Connection, err *HandlerPanicError) { hooked <- err }),
+	})
+
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, hooked)
+	waitDone(t, server)
+	var perr *HandlerPanicError
+	if err := server.Err(); !errors.As(err, &perr) || !errors.Is(err, ErrHandlerPanic) {
+		t.Fatalf("err = %v，期望 *HandlerPanicError", err)
+	}
+	if perr.MsgID != 1 || len(perr.Stack) == 0 {
+		t.Fatalf("MsgID = %d，调用栈 %d 字节", perr.MsgID, len(perr.Stack))
+	}
+}
+
+// ConnectedBegin 中的panic按连接回调处理，MsgID为0
+func TestPanicConnectedBegin(t *testing.T) {
+	hooked := make(chan *HandlerPanicError, 1)
+	a, b := net.Pipe()
+	defer b.Close()
+	h := NewHandlerManager(a, nil, 0, func(ctx context.Context, conn *Connection) { panic("begin") }, nil,
+		WithPanicHook(func(conn *Connection, err *HandlerPanicError) { hooked <- err }))
+	if err := recv(t, hooked); err.MsgID != 0 || err.Value != "begin" {
+		t.Fatalf("hook 收到 %+v", err)
+	}
+	waitDone(t, h)
+	var perr *HandlerPanicError
+	if err := h.Err(); !errors.As(err, &perr) {
+		t.Fatalf("err = %v，期望 *HandlerPanicError", err)
+	}
+}
@@ -0,0 +1,93 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// PreparedMessage 预编码的消息，发送给多个连接时每种编解码器与压缩算法的组合只编码一次
+// 各连接按自己协商的压缩算法发送，与普通消息一致
+type PreparedMessage struct {
+	message *protocol.Message
+	mu      sync.Mutex
+	frames  map[frameKey]preparedFrame
+}
+
+type frameKey struct {
+	codec      protocol.Codec
+	compressor uint8 // 压缩算法ID，0表示不压缩
+}
+
+type preparedFrame struct {
+	data []byte
+	err  error
+}
+
+type preparedKey struct{}
+
+func NewPreparedMessage(MsgID uint32, Data []byte) (*PreparedMessage, error) {
+	if MsgID >= protocol.ReservedMsgID {
+		return nil, ErrReservedMsgID
+	}
+	return &PreparedMessage{
+		message: &protocol.Message{
+			MsgID: MsgID,
+			Data:  Data,
+		},
+		frames: make(map[frameKey]preparedFrame),
+	}, nil
+}
+
+// frame 返回使用compressor压缩、codec编码后的完整帧，compressor为nil时不压缩
+func (p *PreparedMessage) frame(codec protocol.Codec, compressor protocol.Compressor) ([]byte, error) {
+	key := frameKey{codec: codec}
+	if compressor != nil {
+		key.compressor = compressor.ID()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, ok := p.frames[key]
+	if !ok {
+		buf := &bytes.Buffer{}
+		message := p.message
+		if compressor != nil {
+			message, f.err = protocol.Compress(message, compressor)
+		}
+		if f.err == nil {
+			message, f.err = protocol.Pack(message)
+		}
+		if f.err == nil {
+			f.err = codec.MarshalMessage(buf, message)
+		}
+		f.data = buf.Bytes()
+		p.frames[key] = f
+	}
+	return f.data, f.err
+}
+
+// SendPrepared 发送预编码的消息，出站拦截器替换了消息时按普通消息编码
+func (C *Connection) SendPrepared(ctx context.Context, pm *PreparedMessage) error {
+	return C.send(context.WithValue(ctx, preparedKey{}, pm), pm.message)
+}
+
+// SendPreparedAsync 异步发送预编码的消息，入队后立即返回，通过返回值的 Done/Err 获取发送结果
+// 与 SendMsgAsync 不同，队列已满时不等待，策略为 OverflowBlock 时返回 ErrQueueFull
+func (C *Connection) SendPreparedAsync(ctx context.Context, pm *PreparedMessage) *MessageBody {
+	holder := &asyncHolder{}
+	ctx = context.WithValue(ctx, preparedKey{}, pm)
+	ctx = context.WithValue(ctx, asyncKey{}, holder)
+	ctx = context.WithValue(ctx, nonBlockingKey{}, true)
+	err := C.send(ctx, pm.message)
+	if holder.m == nil {
+		// 被出站拦截器拦截，未入队
+		holder.m = NewMessageBody(pm.message)
+		if err == nil {
+			err = ErrDropped
+		}
+		holder.m.ack(err)
+	}
+	return holder.m
+}
@@ -0,0 +1,38 @@
+package connection
+
+import (
+	"context"
+)
+
+// Priority 消息发送优先级，发送协程总是优先发送高优先级的消息
+type Priority uint8
+
+const (
+	PriorityHigh   Priority = iota // 控制帧、延迟敏感的消息
+	PriorityNormal                 // 默认优先级
+	PriorityLow                    // 大批量数据
+
+	priorityLevels = 3
+)
+
+// DefaultStarvationLimit 低优先级消息最多连续让出的次数，超过后强制发送一条低优先级消息
+const DefaultStarvationLimit = 16
+
+type priorityKey struct{}
+
+// ContextWithPriority 返回携带发送优先级的ctx，配合 SendMsgContext、Call 使用
+func ContextWithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p < priorityLevels {
+		return p
+	}
+	return PriorityNormal
+}
+
+// SendMsgWithPriority 以指定优先级发送消息
+func (C *Connection) SendMsgWithPriority(ctx context.Context, MsgID uint32, Data []byte, priority Priority, opts ...SendOption) error {
+	return C.sendMsg(ContextWithPriority(ctx, priority), MsgID, Data, opts)
+}
@@ -0,0 +1,139 @@
+package connection
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/s84662355/simple-message/internal/ratelimit"
+)
+
+var ErrRateLimited = errors.New("消息速率超过限制")
+
+// RatePolicy 入站消息超过速率限制时的处理策略
+type RatePolicy int
+
+const (
+	RateDrop       RatePolicy = iota // 丢弃消息
+	RateDelay                        // 暂停读取直到有令牌，通过TCP背压减缓对端
+	RateDisconnect                   // 以 ErrRateLimited 关闭连接
+)
+
+// RateLimit 速率限制，为0的项不限制
+type RateLimit struct {
+	Messages   float64 // 每秒消息数
+	Burst      int     // 消息数的突发上限，小于1时为1
+	Bytes      float64 // 每秒字节数
+	BurstBytes int     // 字节数的突发上限，小于1时为1
+}
+
+// RateStats 入站限流计数
+type RateStats struct {
+	Delayed uint64 // 被延迟处理的消息数
+	Dropped uint64 // 被丢弃的消息数
+}
+
+type rateCounter struct {
+	delayed atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// RateStats 本连接入站限流的计数
+func (C *Connection) RateStats() RateStats {
+	return RateStats{
+		Delayed: C.rate.delayed.Load(),
+		Dropped: C.rate.dropped.Load(),
+	}
+}
+
+type rateLimiter struct {
+	messages *ratelimit.Bucket
+	bytes    *ratelimit.Bucket
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	r := &rateLimiter{}
+	if limit.Messages > 0 {
+		r.messages = ratelimit.New(limit.Messages, limit.Burst)
+	}
+	if limit.Bytes > 0 {
+		r.bytes = ratelimit.New(limit.Bytes, limit.BurstBytes)
+	}
+	return r
+}
+
+// ready 各令牌桶是否都有足够的令牌，不取走令牌
+func (r *rateLimiter) ready(now time.Time, size int) bool {
+	if r.messages != nil && !r.messages.CanN(now, 1) {
+		return false
+	}
+	if r.bytes != nil && !r.bytes.CanN(now, size) {
+		return false
+	}
+	return true
+}
+
+// take 取走令牌，只在所有限制都通过 ready 检查后调用，被拒绝的消息不消耗令牌
+func (r *rateLimiter) take(now time.Time, size int) {
+	r.reserve(now, size)
+}
+
+func (r *rateLimiter) reserve(now time.Time, size int) time.Duration {
+	var d time.Duration
+	if r.messages != nil {
+		d = r.messages.ReserveN(now, 1)
+	}
+	if r.bytes != nil {
+		d = max(d, r.bytes.ReserveN(now, size))
+	}
+	return d
+}
+
+// limitRate 按连接与MsgID的速率限制检查入站消息，在读协程中调用
+// 返回false表示丢弃该消息，返回错误时关闭连接
+func (h *HandlerManager) limitRate(msgID uint32, size int) (bool, error) {
+	limiters := make([]*rateLimiter, 0, 2)
+	if h.rateLimiter != nil {
+		limiters = append(limiters, h.rateLimiter)
+	}
+	if r, ok := h.msgIDLimiters[msgID]; ok {
+		limiters = append(limiters, r)
+	}
+	if len(limiters) == 0 {
+		return true, nil
+	}
+
+	now := time.Now()
+	if h.cfg.ratePolicy == RateDelay {
+		var d time.Duration
+		for _, r := range limiters {
+			d = max(d, r.reserve(now, size))
+		}
+		if d <= 0 {
+			return true, nil
+		}
+		h.conn.rate.delayed.Add(1)
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true, nil
+		case <-h.ctx.Done():
+			return false, ErrIsClose
+		}
+	}
+
+	for _, r := range limiters {
+		if !r.ready(now, size) {
+			if h.cfg.ratePolicy == RateDisconnect {
+				return false, ErrRateLimited
+			}
+			h.conn.rate.dropped.Add(1)
+			return false, nil
+		}
+	}
+	for _, r := range limiters {
+		r.take(now, size)
+	}
+	return true, nil
+}
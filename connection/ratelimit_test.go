@@ -0,0 +1,88 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// 数据流的数据块计入目标MsgID的速率限制，超过限制时结束整个数据流
+func TestStreamRateLimited(t *testing.T) {
+	got := make(chan error, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		_, err := io.ReadAll(r.GetReader())
+		got <- err
+	})}
+	_, client := newPair(t, handler, []Option{
+		WithMsgIDRateLimit(1, RateLimit{Bytes: 10, BurstBytes: 10}),
+		WithRatePolicy(RateDrop),
+	})
+
+	w, err := client.GetConnection().OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第一个数据块取走桶中全部令牌，第二个超过限制
+	w.Write(make([]byte, 100))
+	w.Write(make([]byte, 100))
+	if err := recv(t, got); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v，期望 ErrRateLimited", err)
+	}
+}
+
+// 控制帧计入连接的速率限制
+func TestControlFramesRateLimited(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	h := NewHandlerManager(a, nil, 0, func(ctx context.Context, conn *Connection) {}, nil,
+		WithRateLimit(RateLimit{Messages: 1, Burst: 5}, RateDisconnect))
+	go io.Copy(io.Discard, b)
+
+	codec := protocol.NewDecoder(0)
+	for range 10 {
+		if err := codec.MarshalMessage(b, &protocol.Message{MsgID: protocol.PingMsgID}); err != nil {
+			break
+		}
+	}
+	waitDone(t, h)
+	if err := h.Err(); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v，期望 ErrRateLimited", err)
+	}
+}
+
+// 被拒绝的消息不消耗任何令牌桶的令牌
+func TestRateLimitRejectedKeepsTokens(t *testing.T) {
+	r := newRateLimiter(RateLimit{Messages: 0.001, Burst: 2, Bytes: 0.001, BurstBytes: 10})
+	now := time.Now()
+	if !r.ready(now, 8) {
+		t.Fatal("首条消息被拒绝")
+	}
+	r.take(now, 8)
+	// 字节数超过限制，消息数的令牌不应被取走
+	if r.ready(now, 8) {
+		t.Fatal("超过字节数限制的消息未被拒绝")
+	}
+	if !r.ready(now, 2) {
+		t.Fatal("被拒绝的消息消耗了令牌")
+	}
+
+	// MsgID的限制拒绝时不消耗连接级别的令牌
+	server, _ := newPair(t, nil, []Option{
+		WithRateLimit(RateLimit{Messages: 0.001, Burst: 2}, RateDrop),
+		WithMsgIDRateLimit(1, RateLimit{Messages: 0.001, Burst: 1}),
+	})
+	for i, want := range []bool{true, false, true} {
+		msgID := uint32(1)
+		if i == 2 {
+			msgID = 2
+		}
+		if ok, err := server.limitRate(msgID, 0); ok != want || err != nil {
+			t.Fatalf("第%d条消息 ok = %v err = %v，期望 %v", i+1, ok, err, want)
+		}
+	}
+}
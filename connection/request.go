@@ -1,15 +1,36 @@
 package connection
 
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
 type IRequest interface {
 	GetConnection() *Connection
 	GetData() []byte
 	GetMsgID() uint32
+	GetReader() io.Reader                     // 数据流请求读取数据流，普通请求读取消息数据
+	Context() context.Context                 // 连接关闭时取消，包含对端传递的链路上下文
+	GetHeader(key string) string              // 对端通过 WithHeader 设置的头部，不存在时为空字符串
+	GetHeaders() protocol.Header              // 全部头部，不可修改
+	Reply(data []byte) error                  // 应答 Call 请求，data 最多为 maxDataLen-protocol.ExtSeqOverhead 字节
+	ReplyError(code uint32, msg string) error // 以错误应答 Call 请求
 }
 
 type Request struct {
-	conn  *Connection
-	data  []byte
-	msgID uint32
+	conn    *Connection
+	data    []byte
+	msgID   uint32
+	flags   uint8
+	seq     uint32
+	replied atomic.Bool
+	reader  io.Reader
+	ctx     context.Context
+	header  protocol.Header
 }
 
 func (m *Request) GetConnection() *Connection {
@@ -23,3 +44,48 @@ func (m *Request) GetData() []byte {
 func (m *Request) GetMsgID() uint32 {
 	return m.msgID
 }
+
+func (m *Request) GetReader() io.Reader {
+	if m.reader != nil {
+		return m.reader
+	}
+	return bytes.NewReader(m.data)
+}
+
+func (m *Request) Context() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+	return m.conn.Ctx()
+}
+
+func (m *Request) GetHeader(key string) string {
+	return m.header.Get(key)
+}
+
+func (m *Request) GetHeaders() protocol.Header {
+	return m.header
+}
+
+func (m *Request) Reply(data []byte) error {
+	return m.reply(protocol.FlagReply, data)
+}
+
+func (m *Request) ReplyError(code uint32, msg string) error {
+	return m.reply(protocol.FlagReply|protocol.FlagError, encodeReplyError(code, msg))
+}
+
+func (m *Request) reply(flags uint8, data []byte) error {
+	if m.flags&protocol.FlagRequest == 0 {
+		return ErrNotRequest
+	}
+	if !m.replied.CompareAndSwap(false, true) {
+		return ErrReplied
+	}
+	return m.conn.send(m.Context(), &protocol.Message{
+		MsgID: m.msgID,
+		Data:  data,
+		Flags: flags,
+		Seq:   m.seq,
+	})
+}
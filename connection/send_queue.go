@@ -0,0 +1,221 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrQueueFull = errors.New("发送队列已满")
+	ErrDropped   = errors.New("消息被丢弃")
+)
+
+// OverflowPolicy 发送队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞直到队列有空位
+	OverflowDropNewest                       // 丢弃新消息，返回 ErrQueueFull
+	OverflowDropOldest                       // 丢弃队列中最旧的消息，被丢弃的消息返回 ErrDropped
+	OverflowClose                            // 以 ErrQueueFull 关闭连接
+)
+
+const (
+	DefaultSendQueueSize = 64
+	DefaultWriteBatch    = 32
+)
+
+// sendQueue 连接的有界发送队列，各优先级共享容量
+// 框架的控制帧单独排队，不占用容量也不受 OverflowPolicy 影响，先于其它消息发送
+type sendQueue struct {
+	mu         sync.Mutex
+	control    []*MessageBody
+	levels     [priorityLevels][]*MessageBody
+	n          int
+	size       int
+	policy     OverflowPolicy
+	starvation int
+	skipped    [priorityLevels]int // 各优先级有消息但连续未被选中的次数
+	closed     bool
+	ready      chan struct{} // 有消息待发送
+	space      chan struct{} // 有空位
+	done       chan struct{} // 队列已关闭
+}
+
+func newSendQueue(size int, policy OverflowPolicy, starvation int) *sendQueue {
+	if size <= 0 {
+		size = DefaultSendQueueSize
+	}
+	if starvation <= 0 {
+		starvation = DefaultStarvationLimit
+	}
+	return &sendQueue{
+		size:       size,
+		policy:     policy,
+		starvation: starvation,
+		ready:      make(chan struct{}, 1),
+		space:      make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// push 消息入队，队列已满时按 OverflowPolicy 处理
+func (q *sendQueue) push(ctx context.Context, m *MessageBody) error {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return ErrIsClose
+		}
+		if q.n < q.size {
+			q.levels[m.priority] = append(q.levels[m.priority], m)
+			q.n++
+			hasSpace := q.n < q.size
+			q.mu.Unlock()
+			signal(q.ready)
+			if hasSpace {
+				// 唤醒其他等待空位的发送者
+				signal(q.space)
+			}
+			return nil
+		}
+
+		switch q.policy {
+		case OverflowDropNewest, OverflowClose:
+			q.mu.Unlock()
+			return ErrQueueFull
+		case OverflowDropOldest:
+			// 丢弃最低优先级中最旧的消息，新消息优先级最低时丢弃新消息
+			l := priorityLevels - 1
+			for len(q.levels[l]) == 0 {
+				l--
+			}
+			if l < int(m.priority) {
+				q.mu.Unlock()
+				return ErrDropped
+			}
+			old := q.levels[l][0]
+			q.levels[l][0] = nil
+			q.levels[l] = q.levels[l][1:]
+			q.levels[m.priority] = append(q.levels[m.priority], m)
+			q.mu.Unlock()
+			old.ack(ErrDropped)
+			signal(q.ready)
+			return nil
+		}
+		q.mu.Unlock()
+		if ctx.Value(nonBlockingKey{}) != nil {
+			return ErrQueueFull
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.done:
+			return ErrIsClose
+		case <-q.space:
+		}
+	}
+}
+
+// pushControl 控制帧入队，不受容量限制
+func (q *sendQueue) pushControl(m *MessageBody) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrIsClose
+	}
+	q.control = append(q.control, m)
+	q.mu.Unlock()
+	signal(q.ready)
+	return nil
+}
+
+// pop 先取出控制帧，再按优先级取出消息，共最多max条
+func (q *sendQueue) pop(max int) []*MessageBody {
+	q.mu.Lock()
+	batch := make([]*MessageBody, 0, min(len(q.control)+q.n, max))
+	for len(batch) < max && len(q.control) > 0 {
+		batch = append(batch, q.control[0])
+		q.control[0] = nil
+		q.control = q.control[1:]
+	}
+	for len(batch) < max && q.n > 0 {
+		l := q.next()
+		batch = append(batch, q.levels[l][0])
+		q.levels[l][0] = nil
+		q.levels[l] = q.levels[l][1:]
+		q.n--
+	}
+	q.mu.Unlock()
+	if len(batch) > 0 {
+		signal(q.space)
+	}
+	return batch
+}
+
+// next 选出本次发送的优先级，低优先级连续让出超过 starvation 次时优先发送
+func (q *sendQueue) next() int {
+	selected := -1
+	for l := priorityLevels - 1; l >= 0; l-- {
+		if len(q.levels[l]) > 0 && q.skipped[l] >= q.starvation {
+			selected = l
+			break
+		}
+	}
+	if selected < 0 {
+		for l := 0; l < priorityLevels; l++ {
+			if len(q.levels[l]) > 0 {
+				selected = l
+				break
+			}
+		}
+	}
+	for l := 0; l < priorityLevels; l++ {
+		if l == selected || len(q.levels[l]) == 0 {
+			q.skipped[l] = 0
+		} else {
+			q.skipped[l]++
+		}
+	}
+	return selected
+}
+
+// Len 队列中待发送的消息数量
+func (q *sendQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.control) + q.n
+}
+
+// close 关闭队列，未发送的消息返回 ErrIsClose
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	control, levels := q.control, q.levels
+	q.control = nil
+	q.levels = [priorityLevels][]*MessageBody{}
+	q.n = 0
+	q.mu.Unlock()
+	close(q.done)
+	for _, m := range control {
+		m.ack(ErrIsClose)
+	}
+	for _, items := range levels {
+		for _, m := range items {
+			m.ack(ErrIsClose)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+func newBody(msgID uint32, priority Priority) *MessageBody {
+	m := NewMessageBody(&protocol.Message{MsgID: msgID})
+	m.priority = priority
+	return m
+}
+
+func popIDs(q *sendQueue, max int) []uint32 {
+	var ids []uint32
+	for _, m := range q.pop(max) {
+		ids = append(ids, m.GetMessage().MsgID)
+	}
+	return ids
+}
+
+func TestSendQueueOverflow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("block", func(t *testing.T) {
+		q := newSendQueue(1, OverflowBlock, 0)
+		if err := q.push(ctx, newBody(1, PriorityNormal)); err != nil {
+			t.Fatal(err)
+		}
+		timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if err := q.push(timeout, newBody(2, PriorityNormal)); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v，期望 DeadlineExceeded", err)
+		}
+		pushed := make(chan error, 1)
+		go func() { pushed <- q.push(ctx, newBody(3, PriorityNormal)) }()
+		q.pop(1)
+		if err := recv(t, pushed); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		q := newSendQueue(1, OverflowDropNewest, 0)
+		q.push(ctx, newBody(1, PriorityNormal))
+		if err := q.push(ctx, newBody(2, PriorityNormal)); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("err = %v，期望 ErrQueueFull", err)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		q := newSendQueue(2, OverflowDropOldest, 0)
+		oldest := newBody(1, PriorityLow)
+		q.push(ctx, oldest)
+		q.push(ctx, newBody(2, PriorityLow))
+		if err := q.push(ctx, newBody(3, PriorityNormal)); err != nil {
+			t.Fatal(err)
+		}
+		<-oldest.Done()
+		if !errors.Is(oldest.Err(), ErrDropped) {
+			t.Fatalf("err = %v，期望 ErrDropped", oldest.Err())
+		}
+		// 新消息优先级低于队列中所有消息时丢弃新消息
+		q = newSendQueue(1, OverflowDropOldest, 0)
+		q.push(ctx, newBody(1, PriorityHigh))
+		if err := q.push(ctx, newBody(2, PriorityLow)); !errors.Is(err, ErrDropped) {
+			t.Fatalf("err = %v，期望 ErrDropped", err)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		conn := newConnection(nil, &config{sendQueueSize: 1, overflowPolicy: OverflowClose})
+		conn.SendMsgAsync(1, nil)
+		if err := conn.SendMsgAsync(2, nil).Err(); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("err = %v，期望 ErrQueueFull", err)
+		}
+		if !errors.Is(conn.Err(), ErrQueueFull) {
+			t.Fatalf("连接错误 %v，期望 ErrQueueFull", conn.Err())
+		}
+	})
+}
+
+// 控制帧不占用容量，不受溢出策略影响，先于其它消息发送
+func TestSendQueueControl(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowDropOldest, OverflowClose} {
+		q := newSendQueue(1, policy, 0)
+		q.push(ctx, newBody(1, PriorityHigh))
+		if err := q.pushControl(newBody(protocol.PingMsgID, PriorityHigh)); err != nil {
+			t.Fatalf("policy %d: %v", policy, err)
+		}
+		if ids := popIDs(q, 2); len(ids) != 2 || ids[0] != protocol.PingMsgID {
+			t.Fatalf("policy %d: 发送顺序 %v", policy, ids)
+		}
+	}
+
+	conn := newConnection(nil, &config{sendQueueSize: 1, overflowPolicy: OverflowClose})
+	conn.SendMsgAsync(1, nil)
+	ctx = context.WithValue(context.WithValue(ctx, asyncKey{}, &asyncHolder{}), controlKey{}, true)
+	conn.enqueue(ctx, &protocol.Message{MsgID: protocol.PingMsgID})
+	if conn.Err() != nil {
+		t.Fatalf("控制帧导致连接关闭: %v", conn.Err())
+	}
+	if n := conn.QueueLen(); n != 2 {
+		t.Fatalf("队列长度 %d，期望 2", n)
+	}
+}
+
+func TestSendQueuePriority(t *testing.T) {
+	ctx := context.Background()
+	q := newSendQueue(10, OverflowBlock, 0)
+	q.push(ctx, newBody(3, PriorityLow))
+	q.push(ctx, newBody(2, PriorityNormal))
+	q.push(ctx, newBody(1, PriorityHigh))
+	ids := popIDs(q, 10)
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Fatalf("发送顺序 %v", ids)
+	}
+}
+
+// 低优先级连续让出 starvation 次后发送一条
+func TestSendQueueStarvation(t *testing.T) {
+	ctx := context.Background()
+	q := newSendQueue(100, OverflowBlock, 2)
+	q.push(ctx, newBody(100, PriorityLow))
+	for i := uint32(1); i <= 5; i++ {
+		q.push(ctx, newBody(i, PriorityHigh))
+	}
+	var ids []uint32
+	for q.Len() > 0 {
+		ids = append(ids, popIDs(q, 1)...)
+	}
+	if len(ids) != 6 || ids[2] != 100 {
+		t.Fatalf("发送顺序 %v，期望低优先级消息第3个发送", ids)
+	}
+}
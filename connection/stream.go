@@ -0,0 +1,382 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+var (
+	ErrStreamClosed   = errors.New("数据流已关闭")
+	ErrStreamReset    = errors.New("数据流被重置")
+	ErrStreamTooLarge = errors.New("数据流超过大小限制")
+	ErrTooManyStreams = errors.New("数据流数量超过限制")
+	ErrStreamExists   = errors.New("数据流ID重复")
+	ErrStreamOverflow = errors.New("数据流超过流控窗口")
+)
+
+const (
+	DefaultStreamChunkSize = 4 * 1024
+	DefaultMaxStreamSize   = 64 * 1024 * 1024
+	DefaultMaxStreams      = 100
+	streamBufferChunks     = 16                     // 读端缓存的数据块数，也是写端的初始窗口
+	streamWindowUpdate     = streamBufferChunks / 2 // 读端每读取这么多数据块归还一次窗口
+)
+
+// 数据流帧类型，帧格式: 1字节类型 + 4字节流ID + 类型相关数据
+const (
+	streamOpen   = iota + 1 // 打开，携带4字节MsgID
+	streamData              // 数据块
+	streamClose             // 写端正常结束
+	streamAbort             // 写端异常结束，携带原因
+	streamReset             // 读端拒绝或提前结束，携带原因
+	streamWindow            // 读端归还窗口，携带4字节数据块数
+)
+
+const streamHeaderLen = 5
+
+func streamFrame(kind byte, id uint32, payload []byte) *protocol.Message {
+	b := make([]byte, streamHeaderLen, streamHeaderLen+len(payload))
+	b[0] = kind
+	binary.BigEndian.PutUint32(b[1:], id)
+	return &protocol.Message{
+		MsgID: protocol.StreamMsgID,
+		Data:  append(b, payload...),
+	}
+}
+
+// StreamWriter 数据流写端，写入的数据被切分为数据块帧，与普通消息交错发送
+// 已发送但对端未读取的数据块达到 streamBufferChunks 时 Write 阻塞，慢的读端不会阻塞连接上的其它消息
+type StreamWriter struct {
+	conn      *Connection
+	id        uint32
+	chunkSize int
+	mu        sync.Mutex
+	closed    bool
+	resetMu   sync.Mutex
+	resetErr  error
+	window    int           // 还能发送的数据块数，由 resetMu 保护
+	wake      chan struct{} // 窗口增加或数据流被重置时通知
+}
+
+// OpenStream 打开一个数据流，对端 MsgID 对应的处理器通过 IRequest.GetReader 读取
+func (C *Connection) OpenStream(MsgID uint32) (*StreamWriter, error) {
+	return C.OpenStreamContext(context.TODO(), MsgID)
+}
+
+func (C *Connection) OpenStreamContext(ctx context.Context, MsgID uint32) (*StreamWriter, error) {
+	if MsgID >= protocol.ReservedMsgID {
+		return nil, ErrReservedMsgID
+	}
+	w := &StreamWriter{
+		conn:      C,
+		id:        C.streamSeq.Add(1),
+		chunkSize: C.streamChunkSize,
+		window:    streamBufferChunks,
+		wake:      make(chan struct{}, 1),
+	}
+	if hs := C.Handshake(); hs != nil && hs.MaxDataLen > streamHeaderLen {
+		// 数据块不超过对端能接收的最大数据长度
+		w.chunkSize = min(w.chunkSize, int(hs.MaxDataLen-streamHeaderLen))
+	}
+	C.streamWriters.Store(w.id, w)
+	if err := C.enqueue(ctx, streamFrame(streamOpen, w.id, binary.BigEndian.AppendUint32(nil, MsgID))); err != nil {
+		C.streamWriters.Delete(w.id)
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrStreamClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		if err := w.err(); err != nil {
+			return n, err
+		}
+		if err := w.acquire(); err != nil {
+			return n, err
+		}
+		chunk := p[:min(len(p), w.chunkSize)]
+		ctx := ContextWithPriority(w.conn.ctx, PriorityLow)
+		if err := w.conn.enqueue(ctx, streamFrame(streamData, w.id, chunk)); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// Close 正常结束数据流，对端读取完后收到 io.EOF
+func (w *StreamWriter) Close() error {
+	return w.finish(streamClose, nil)
+}
+
+// CloseWithError 异常结束数据流，对端读取时收到包装了 ErrStreamReset 的错误
+func (w *StreamWriter) CloseWithError(err error) error {
+	return w.finish(streamAbort, []byte(err.Error()))
+}
+
+func (w *StreamWriter) finish(kind byte, payload []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrStreamClosed
+	}
+	w.closed = true
+	defer w.conn.streamWriters.Delete(w.id)
+	if err := w.err(); err != nil {
+		return err
+	}
+	return w.conn.enqueue(ContextWithPriority(w.conn.ctx, PriorityLow), streamFrame(kind, w.id, payload))
+}
+
+func (w *StreamWriter) err() error {
+	w.resetMu.Lock()
+	defer w.resetMu.Unlock()
+	return w.resetErr
+}
+
+func (w *StreamWriter) reset(err error) {
+	w.resetMu.Lock()
+	defer w.resetMu.Unlock()
+	if w.resetErr == nil {
+		w.resetErr = err
+	}
+	w.notify()
+}
+
+// acquire 占用一个数据块的窗口，窗口为0时等待对端归还
+func (w *StreamWriter) acquire() error {
+	for {
+		w.resetMu.Lock()
+		err, ok := w.resetErr, w.window > 0
+		if err == nil && ok {
+			w.window--
+		}
+		w.resetMu.Unlock()
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-w.wake:
+		case <-w.conn.ctx.Done():
+			return ErrIsClose
+		}
+	}
+}
+
+// grant 对端归还了n个数据块的窗口
+func (w *StreamWriter) grant(n int) {
+	w.resetMu.Lock()
+	defer w.resetMu.Unlock()
+	w.window += n
+	w.notify()
+}
+
+func (w *StreamWriter) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// streamReader 数据流读端，由读协程写入数据块，处理器协程读取
+type streamReader struct {
+	id       uint32
+	msgID    uint32
+	chunks   chan []byte
+	cur      []byte
+	consumed int         // 已读取但未归还窗口的数据块数，只在处理器协程中访问
+	update   func(n int) // 向写端归还窗口
+	size     int64
+	errMu    sync.Mutex
+	err      error
+	done     chan struct{} // 处理器已返回
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.cur) == 0 {
+		chunk, ok := <-r.chunks
+		if !ok {
+			r.errMu.Lock()
+			defer r.errMu.Unlock()
+			return 0, r.err
+		}
+		r.cur = chunk
+		if r.consumed++; r.consumed >= streamWindowUpdate {
+			r.update(r.consumed)
+			r.consumed = 0
+		}
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// end 结束数据流，读取完已缓存的数据块后返回err，只能由读协程调用一次
+func (r *streamReader) end(err error) {
+	r.errMu.Lock()
+	r.err = err
+	r.errMu.Unlock()
+	close(r.chunks)
+}
+
+// handleStream 处理数据流帧，只在读协程中调用
+func (h *HandlerManager) handleStream(data []byte) error {
+	if len(data) < streamHeaderLen {
+		return protocol.ErrExtFrame
+	}
+	kind, id, payload := data[0], binary.BigEndian.Uint32(data[1:]), data[streamHeaderLen:]
+
+	switch kind {
+	case streamOpen:
+		if len(payload) < 4 {
+			return protocol.ErrExtFrame
+		}
+		msgID := binary.BigEndian.Uint32(payload)
+		if ok, err := h.limitRate(msgID, 0); !ok {
+			if err != nil {
+				return err
+			}
+			h.resetStream(id, ErrRateLimited)
+			return nil
+		}
+		h.openStream(id, msgID)
+	case streamData:
+		r, ok := h.streams[id]
+		if !ok {
+			return nil
+		}
+		// 数据块计入连接与目标MsgID的速率限制，丢弃数据块会损坏数据流，因此结束整个数据流
+		if ok, err := h.limitRate(r.msgID, len(payload)); !ok {
+			if err != nil {
+				return err
+			}
+			delete(h.streams, id)
+			r.end(fmt.Errorf("%w: %w", ErrStreamReset, ErrRateLimited))
+			h.resetStream(id, ErrRateLimited)
+			return nil
+		}
+		r.size += int64(len(payload))
+		if r.size > h.cfg.maxStreamSize {
+			delete(h.streams, id)
+			r.end(fmt.Errorf("%w 不得大于%d", ErrStreamTooLarge, h.cfg.maxStreamSize))
+			h.resetStream(id, ErrStreamTooLarge)
+			return nil
+		}
+		select {
+		case <-r.done:
+			// 处理器已返回，丢弃剩余数据
+			delete(h.streams, id)
+			r.end(ErrStreamClosed)
+			h.resetStream(id, ErrStreamClosed)
+			return nil
+		default:
+		}
+		// 写端遵守窗口时缓存不会满，读协程不等待处理器读取
+		select {
+		case r.chunks <- payload:
+		default:
+			delete(h.streams, id)
+			r.end(fmt.Errorf("%w: %w", ErrStreamReset, ErrStreamOverflow))
+			h.resetStream(id, ErrStreamOverflow)
+		}
+	case streamClose, streamAbort:
+		r, ok := h.streams[id]
+		if !ok {
+			return nil
+		}
+		delete(h.streams, id)
+		if kind == streamClose {
+			r.end(io.EOF)
+		} else {
+			r.end(fmt.Errorf("%w: %s", ErrStreamReset, payload))
+		}
+	case streamReset:
+		if w, ok := h.conn.streamWriters.Load(id); ok {
+			w.(*StreamWriter).reset(fmt.Errorf("%w: %s", ErrStreamReset, payload))
+		}
+	case streamWindow:
+		if len(payload) < 4 {
+			return protocol.ErrExtFrame
+		}
+		if w, ok := h.conn.streamWriters.Load(id); ok {
+			w.(*StreamWriter).grant(int(binary.BigEndian.Uint32(payload)))
+		}
+	default:
+		return protocol.ErrExtFrame
+	}
+	return nil
+}
+
+func (h *HandlerManager) openStream(id, msgID uint32) {
+	if r, ok := h.streams[id]; ok {
+		// 对端重复使用未结束的流ID，结束原数据流并拒绝本次打开
+		delete(h.streams, id)
+		r.end(fmt.Errorf("%w: %w", ErrStreamReset, ErrStreamExists))
+		h.resetStream(id, ErrStreamExists)
+		return
+	}
+	if int(h.activeStreams.Load()) >= h.cfg.maxStreams {
+		h.resetStream(id, ErrTooManyStreams)
+		return
+	}
+	handler, ok := h.handler[msgID]
+	if !ok || !h.allowed(msgID) || !h.handlers.add() {
+		h.resetStream(id, ErrStreamClosed)
+		return
+	}
+	r := &streamReader{
+		id:     id,
+		msgID:  msgID,
+		chunks: make(chan []byte, streamBufferChunks),
+		done:   make(chan struct{}),
+		update: func(n int) {
+			h.enqueueControl(streamFrame(streamWindow, id, binary.BigEndian.AppendUint32(nil, uint32(n))))
+		},
+	}
+	h.streams[id] = r
+	h.activeStreams.Add(1)
+
+	// 数据流处理器需要与读协程并发执行，不经过调度器，并发数由 WithMaxStreams 限制
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer h.handlers.done()
+		defer h.observeHandler(msgID, time.Now())
+		defer h.activeStreams.Add(-1)
+		defer close(r.done)
+		h.Protect(msgID, func() {
+			handler.Handle(&Request{
+				conn:   h.conn,
+				msgID:  msgID,
+				reader: r,
+			})
+		})
+	}()
+}
+
+// resetStream 通知写端停止写入，在读协程中调用，不等待写入完成
+func (h *HandlerManager) resetStream(id uint32, err error) {
+	h.enqueueControl(streamFrame(streamReset, id, []byte(err.Error())))
+}
+
+// abortStreams 读协程退出时结束所有未完成的数据流
+func (h *HandlerManager) abortStreams(err error) {
+	for id, r := range h.streams {
+		delete(h.streams, id)
+		r.end(err)
+	}
+}
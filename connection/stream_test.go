@@ -0,0 +1,203 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+func openFrame(id, msgID uint32) *protocol.Message {
+	return streamFrame(streamOpen, id, binary.BigEndian.AppendUint32(nil, msgID))
+}
+
+func TestStream(t *testing.T) {
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		data, _ := io.ReadAll(r.GetReader())
+		got <- data
+	})}
+	_, client := newPair(t, handler, nil)
+
+	w, err := client.GetConnection().OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, 3*DefaultStreamChunkSize+1)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != string(payload) {
+		t.Fatalf("收到%d字节，期望%d字节", len(data), len(payload))
+	}
+}
+
+func TestStreamTooLarge(t *testing.T) {
+	got := make(chan error, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		_, err := io.ReadAll(r.GetReader())
+		got <- err
+	})}
+	_, client := newPair(t, handler, []Option{WithMaxStreamSize(10)})
+
+	w, err := client.GetConnection().OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(make([]byte, 11))
+	if err := recv(t, got); !errors.Is(err, ErrStreamTooLarge) {
+		t.Fatalf("err = %v，期望 ErrStreamTooLarge", err)
+	}
+}
+
+func TestStreamDuplicateID(t *testing.T) {
+	got := make(chan error, 2)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		_, err := io.ReadAll(r.GetReader())
+		got <- err
+	})}
+	server, client := newPair(t, handler, nil)
+
+	conn := client.GetConnection()
+	ctx := context.Background()
+	if err := conn.send(ctx, openFrame(7, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.send(ctx, openFrame(7, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv(t, got); !errors.Is(err, ErrStreamExists) {
+		t.Fatalf("err = %v，期望 ErrStreamExists", err)
+	}
+
+	// 原数据流已结束，对端关闭后连接可以正常结束
+	client.Stop()
+	waitDone(t, server)
+}
+
+func TestMaxStreams(t *testing.T) {
+	release := make(chan struct{})
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		<-release
+	})}
+	_, client := newPair(t, handler, []Option{WithMaxStreams(1)})
+	defer close(release)
+
+	conn := client.GetConnection()
+	first, err := conn.OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := conn.OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return second.err() != nil })
+	if err := second.err(); !errors.Is(err, ErrStreamReset) {
+		t.Fatalf("err = %v，期望 ErrStreamReset", err)
+	}
+}
+
+// 超过窗口的数据流需要读端归还窗口后才能继续发送
+func TestStreamWindow(t *testing.T) {
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		data, _ := io.ReadAll(r.GetReader())
+		got <- data
+	})}
+	_, client := newPair(t, handler, nil)
+
+	w, err := client.GetConnection().OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, 4*streamBufferChunks*DefaultStreamChunkSize)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != string(payload) {
+		t.Fatalf("收到%d字节，期望%d字节", len(data), len(payload))
+	}
+}
+
+// 数据流处理器不读取时，普通消息不受影响
+func TestStreamStalledHandler(t *testing.T) {
+	release := make(chan struct{})
+	got := make(chan []byte, 1)
+	handler := map[uint32]Handler{
+		1: HandlerFunc(func(r IRequest) { <-release }),
+		2: HandlerFunc(func(r IRequest) { got <- r.GetData() }),
+	}
+	_, client := newPair(t, handler, nil)
+	defer close(release)
+
+	w, err := client.GetConnection().OpenStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan error, 1)
+	go func() {
+		_, err := w.Write(make([]byte, 160*1024))
+		written <- err
+	}()
+	if err := client.GetConnection().SendMsg(2, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+	// 写端在窗口用完后等待
+	select {
+	case err := <-written:
+		t.Fatalf("窗口用完后 Write 已返回: %v", err)
+	default:
+	}
+}
+
+// 写端不遵守窗口时重置数据流，不阻塞读协程
+func TestStreamOverflow(t *testing.T) {
+	got := make(chan error, 1)
+	release := make(chan struct{})
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) {
+		<-release
+		_, err := io.ReadAll(r.GetReader())
+		got <- err
+	}), 2: HandlerFunc(func(r IRequest) {
+		close(release)
+	})}
+	_, client := newPair(t, handler, nil)
+
+	conn := client.GetConnection()
+	ctx := context.Background()
+	if err := conn.send(ctx, openFrame(7, 1)); err != nil {
+		t.Fatal(err)
+	}
+	for range streamBufferChunks + 1 {
+		if err := conn.send(ctx, streamFrame(streamData, 7, []byte("x"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 读协程按顺序处理，处理器收到该消息时所有数据块都已处理
+	if err := conn.SendMsg(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv(t, got); !errors.Is(err, ErrStreamOverflow) {
+		t.Fatalf("err = %v，期望 ErrStreamOverflow", err)
+	}
+}
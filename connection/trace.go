@@ -0,0 +1,131 @@
+package connection
+
+import (
+	"context"
+	"strings"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// W3C Trace Context 使用的头部
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// TextMapCarrier 头部载体，与 OpenTelemetry 的 propagation.TextMapCarrier 方法一致
+type TextMapCarrier interface {
+	Get(key string) string
+	Set(key, value string)
+	Keys() []string
+}
+
+// Propagator 在消息头部与ctx之间传递链路上下文
+// 接入 OpenTelemetry 时用 otel.GetTextMapPropagator() 包装一层即可
+type Propagator interface {
+	Inject(ctx context.Context, carrier TextMapCarrier)
+	Extract(ctx context.Context, carrier TextMapCarrier) context.Context
+}
+
+// TraceContext W3C Trace Context
+type TraceContext struct {
+	Traceparent string
+	Tracestate  string
+}
+
+type traceKey struct{}
+
+// ContextWithTrace 将链路上下文放入ctx，通过该ctx发送的消息会携带链路上下文
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceKey{}, tc)
+}
+
+// TraceFromContext 从ctx中取出链路上下文
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey{}).(TraceContext)
+	return tc, ok
+}
+
+// W3CPropagator 通过 ContextWithTrace 传递 traceparent 与 tracestate
+type W3CPropagator struct{}
+
+func (W3CPropagator) Inject(ctx context.Context, carrier TextMapCarrier) {
+	tc, ok := TraceFromContext(ctx)
+	if !ok || !validTraceparent(tc.Traceparent) {
+		return
+	}
+	carrier.Set(TraceparentHeader, tc.Traceparent)
+	if tc.Tracestate != "" {
+		carrier.Set(TracestateHeader, tc.Tracestate)
+	}
+}
+
+func (W3CPropagator) Extract(ctx context.Context, carrier TextMapCarrier) context.Context {
+	tp := carrier.Get(TraceparentHeader)
+	if !validTraceparent(tp) {
+		return ctx
+	}
+	return ContextWithTrace(ctx, TraceContext{
+		Traceparent: tp,
+		Tracestate:  carrier.Get(TracestateHeader),
+	})
+}
+
+// validTraceparent 检查 traceparent 格式: 2位版本-32位trace-id-16位parent-id-2位标志，均为小写十六进制
+func validTraceparent(s string) bool {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return false
+	}
+	for i, n := range []int{2, 32, 16, 2} {
+		if len(parts[i]) != n || !isLowerHex(parts[i]) {
+			return false
+		}
+	}
+	return strings.Trim(parts[1], "0") != "" && strings.Trim(parts[2], "0") != ""
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// inject 将ctx中的链路上下文写入消息头部，返回新的消息，不修改原消息
+// 预编码的消息与不支持头部的对端不注入
+func (C *Connection) inject(ctx context.Context, message *protocol.Message) *protocol.Message {
+	if C.propagator == nil || ctx.Value(preparedKey{}) != nil {
+		return message
+	}
+	if hs := C.Handshake(); hs != nil && !hs.Has(FeatureHeader) {
+		return message
+	}
+	header := make(protocol.Header, len(message.Header)+2)
+	for k, v := range message.Header {
+		header[k] = v
+	}
+	C.propagator.Inject(ctx, header)
+	if len(header) == len(message.Header) {
+		return message
+	}
+	m := *message
+	m.Header = header
+	return &m
+}
+
+// requestContext 处理器使用的ctx，包含从消息头部提取的链路上下文
+func (h *HandlerManager) requestContext(header protocol.Header) context.Context {
+	ctx := h.conn.Ctx()
+	if h.conn.propagator == nil || len(header) == 0 {
+		return ctx
+	}
+	return h.conn.propagator.Extract(ctx, header)
+}
+
+var _ TextMapCarrier = protocol.Header(nil)
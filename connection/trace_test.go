@@ -0,0 +1,89 @@
+package connection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/protocol"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// 发送端从ctx注入链路上下文，接收端通过 IRequest.Context 取出
+func TestTracePropagation(t *testing.T) {
+	got := make(chan IRequest, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r })}
+	opt := WithPropagator(W3CPropagator{})
+	_, client := newPair(t, handler, []Option{opt}, opt)
+
+	ctx := ContextWithTrace(context.Background(), TraceContext{Traceparent: testTraceparent, Tracestate: "k=v"})
+	if err := client.GetConnection().SendMsgContext(ctx, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := recv(t, got)
+	tc, ok := TraceFromContext(r.Context())
+	if !ok || tc.Traceparent != testTraceparent || tc.Tracestate != "k=v" {
+		t.Fatalf("链路上下文 %+v, %v", tc, ok)
+	}
+	if r.Context().Err() != nil {
+		t.Fatal("处理器的ctx已取消")
+	}
+
+	// 不带链路上下文的消息不注入头部
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	r = recv(t, got)
+	if _, ok := TraceFromContext(r.Context()); ok || len(r.GetHeaders()) != 0 {
+		t.Fatalf("头部 %v", r.GetHeaders())
+	}
+}
+
+func TestTraceparentInvalid(t *testing.T) {
+	for _, tp := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",     // 缺少标志
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-", // 版本00不允许多余字段
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",  // 无效版本
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",  // trace-id全为0
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",  // parent-id全为0
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",  // 大写
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",   // trace-id长度错误
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",  // 非十六进制
+	} {
+		header := protocol.Header{TraceparentHeader: tp}
+		if _, ok := TraceFromContext(W3CPropagator{}.Extract(context.Background(), header)); ok {
+			t.Errorf("Extract 接受了 %q", tp)
+		}
+		header = protocol.Header{}
+		W3CPropagator{}.Inject(ContextWithTrace(context.Background(), TraceContext{Traceparent: tp}), header)
+		if len(header) != 0 {
+			t.Errorf("Inject 写入了 %q", tp)
+		}
+	}
+	if !validTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future") {
+		t.Error("更高版本允许多余字段")
+	}
+}
+
+// 握手确认对端是旧版本后不注入链路上下文
+func TestTraceLegacyPeer(t *testing.T) {
+	got := make(chan IRequest, 1)
+	handler := map[uint32]Handler{1: HandlerFunc(func(r IRequest) { got <- r })}
+	_, client := newPair(t, handler, []Option{WithPropagator(W3CPropagator{})},
+		WithPropagator(W3CPropagator{}), WithHandshake(20*time.Millisecond))
+	eventually(t, func() bool { return client.GetConnection().Handshake() != nil })
+	if !client.GetConnection().Handshake().Legacy {
+		t.Fatal("期望 Legacy")
+	}
+
+	ctx := ContextWithTrace(context.Background(), TraceContext{Traceparent: testTraceparent})
+	if err := client.GetConnection().SendMsgContext(ctx, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := recv(t, got)
+	if len(r.GetHeaders()) != 0 {
+		t.Fatalf("头部 %v，期望不注入", r.GetHeaders())
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -22,25 +21,9 @@ func (h *Handler1) Handle(request connection.IRequest) {
 		string(request.GetData()))
 }
 
-type Action struct{}
-
-func (a *Action) DialContext(ctx context.Context) (connection.Conn, any, error) {
-	var d net.Dialer
-	// 连接到本地2000端口的TCP服务器
-	conn, err := d.DialContext(ctx, "tcp", "127.0.0.1:2000")
-	if err != nil {
-		return nil, nil, fmt.Errorf("连接失败: %w", err)
-	}
-
-	// 可选：配置TCP连接属性（如心跳机制）
-	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		// 启用TCP保活机制
-		if err := tcpConn.SetKeepAlive(true); err != nil {
-			fmt.Printf("设置TCP保活失败: %v\n", err)
-		}
-	}
-	///进行一些握手之类的操作
-	return conn, "token", nil
+// Action 嵌入内置拨号器实现 DialContext
+type Action struct {
+	*client.NetDialer
 }
 
 // 拨号错误回调 - 返回新的拨号函数用于重连
@@ -67,7 +50,7 @@ func main() {
 
 		handlers,  // 消息处理器映射
 		1024*1024, // 最大数据长度 (1MB)
-		new(Action),
+		&Action{NetDialer: client.NewTCPDialer("127.0.0.1:2000")}, // 连接到本地2000端口的TCP服务器
 	)
 
 	// 确保程序退出时正确停止客户端
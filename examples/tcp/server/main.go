@@ -2,9 +2,7 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -25,38 +23,6 @@ func (h *Handler1) Handle(request connection.IRequest) {
 	// 此处可添加消息处理逻辑
 }
 
-// Listener 自定义监听器实现，包装net.Listener
-type Listener struct {
-	listener net.Listener
-}
-
-// Accept 实现Accept接口，返回读写关闭器
-func (l *Listener) Accept() (connection.Conn, any, error) {
-	for {
-		if conn, err := l.listener.Accept(); err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				fmt.Println("监听器已关闭，正常退出")
-				return nil, nil, err
-			}
-
-			// 2. 判断是否为临时错误（可重试）
-			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
-				fmt.Printf("临时错误: %v，将重试\n", err)
-				continue
-			}
-
-		} else {
-			///进行一些握手之类的操作
-			return conn, "token", nil
-		}
-	}
-}
-
-// Close 实现关闭接口
-func (l *Listener) Close() error {
-	return l.listener.Close()
-}
-
 type Action struct{}
 
 // 连接错误回调
@@ -74,8 +40,8 @@ func (l *Action) ConnectedBegin(ctx context.Context, conn *connection.Connection
 }
 
 func main() {
-	// 创建TCP监听器，监听2000端口
-	listener, err := net.Listen("tcp", ":2000")
+	// 创建TCP监听器，监听2000端口，临时错误自动重试
+	listener, err := server.ListenTCP(":2000")
 	if err != nil {
 		fmt.Printf("创建监听器失败: %v\n", err)
 		return
@@ -87,14 +53,9 @@ func main() {
 		1: &Handler1{},
 	}
 
-	// 包装自定义监听器
-	customListener := &Listener{
-		listener: listener,
-	}
-
 	// 创建服务器实例
 	srv := server.NewServer(
-		customListener,
+		listener,
 		handlers,
 		1024*1024, // 最大数据长度 (1MB)
 		1024,      // 最大连接数
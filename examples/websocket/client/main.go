@@ -7,10 +7,9 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/gorilla/websocket"
 	"github.com/s84662355/simple-message/client"
 	"github.com/s84662355/simple-message/connection"
-	www "github.com/s84662355/simple-message/examples/websocket"
+	"github.com/s84662355/simple-message/websocket"
 )
 
 // Handler1 消息处理器，用于处理MsgID=1的消息
@@ -23,19 +22,9 @@ func (h *Handler1) Handle(request connection.IRequest) {
 		string(request.GetData()))
 }
 
-type Action struct{}
-
-func (a *Action) DialContext(ctx context.Context) (connection.Conn, any, error) {
-	conn, _, err := websocket.DefaultDialer.Dial("ws://localhost:18080/test", nil)
-	if err != nil {
-		fmt.Printf("websocket连接错误: %v, 准备重连...\n", err)
-		return nil, nil, err
-	}
-
-	wConn, _ := www.NewWebSocketConn(conn)
-
-	///进行一些握手之类的操作
-	return wConn, "token", nil
+// Action 嵌入WebSocket拨号器实现 DialContext
+type Action struct {
+	*websocket.Dialer
 }
 
 // 拨号错误回调 - 返回新的拨号函数用于重连
@@ -57,12 +46,16 @@ func main() {
 		1: &Handler1{},
 	}
 
+	dialer := websocket.NewDialer("ws://localhost:18080/test")
+	dialer.MaxDataLen = 1024 * 1024 // 与最大数据长度一致，限制单条WebSocket消息的长度
+
 	// 创建客户端实例
 	c := client.NewClient(
 
 		handlers,  // 消息处理器映射
 		1024*1024, // 最大数据长度 (1MB)
-		new(Action),
+		&Action{Dialer: dialer},
+		websocket.ClientOption(1024*1024), // 每条WebSocket消息一个协议帧
 	)
 
 	// 确保程序退出时正确停止客户端
@@ -9,8 +9,8 @@ import (
 	"syscall"
 
 	"github.com/s84662355/simple-message/connection"
-	www "github.com/s84662355/simple-message/examples/websocket"
 	"github.com/s84662355/simple-message/server"
+	"github.com/s84662355/simple-message/websocket"
 )
 
 // Handler1 消息处理器，用于处理MsgID=1的消息
@@ -42,7 +42,7 @@ func (l *Action) ConnectedBegin(ctx context.Context, conn *connection.Connection
 }
 
 func main() {
-	customListener, err := www.NewWebSocketListener(":18080", "/test")
+	customListener, err := websocket.Listen(":18080", "/test", websocket.WithMaxDataLen(1024*1024))
 	if err != nil {
 		log.Println("创建websocket失败:", err)
 		return
@@ -60,6 +60,7 @@ func main() {
 		1024*1024, // 最大数据长度 (1MB)
 		1024,      // 最大连接数
 		new(Action),
+		websocket.ServerOption(1024*1024), // 每条WebSocket消息一个协议帧
 	)
 
 	// 启动服务器，使用16个accept协程
@@ -2,7 +2,4 @@ module github.com/s84662355/simple-message
 
 go 1.23.4
 
-require (
-	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/s84662355/nqueue v0.0.0-20250906090220-e56d62ad8b24 // indirect
-)
+require github.com/gorilla/websocket v1.5.3
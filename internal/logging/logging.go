@@ -0,0 +1,25 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net"
+)
+
+// Discard 丢弃所有日志，未配置 logger 时使用
+var Discard = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// Addr 地址属性，addr 为nil时记录为空字符串
+func Addr(key string, addr net.Addr) slog.Attr {
+	if addr == nil {
+		return slog.String(key, "")
+	}
+	return slog.String(key, addr.String())
+}
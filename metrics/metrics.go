@@ -0,0 +1,74 @@
+// Package metrics 框架内部指标的采集接口与 Prometheus 文本格式的实现
+package metrics
+
+// Label 指标标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sink 指标接收器，需要并发安全
+// 同一个指标名称总是使用相同的标签名称
+type Sink interface {
+	// IncCounter 计数器增加value
+	IncCounter(name string, value float64, labels ...Label)
+	// AddGauge 仪表值增加value，value可以为负数
+	AddGauge(name string, value float64, labels ...Label)
+	// Observe 向直方图记录一个观测值
+	Observe(name string, value float64, labels ...Label)
+}
+
+// 框架采集的指标
+const (
+	ConnectionsActive      = "simple_message_connections_active"
+	ConnectionsClosed      = "simple_message_connections_closed_total"
+	BytesReceived          = "simple_message_bytes_received_total"
+	BytesSent              = "simple_message_bytes_sent_total"
+	FramesReceived         = "simple_message_frames_received_total"
+	FramesSent             = "simple_message_frames_sent_total"
+	HandlerDuration        = "simple_message_handler_duration_seconds"
+	SendQueueDepth         = "simple_message_send_queue_depth"
+	DecodeErrors           = "simple_message_decode_errors_total"
+	ServerAccepted         = "simple_message_server_accepted_total"
+	ServerRejected         = "simple_message_server_rejected_total"
+	ClientDialErrors       = "simple_message_client_dial_errors_total"
+	ClientReconnects       = "simple_message_client_reconnects_total"
+	ClientConnectedSeconds = "simple_message_client_connected_seconds"
+)
+
+var help = map[string]string{
+	ConnectionsActive:      "当前活跃的连接数",
+	ConnectionsClosed:      "按原因统计的已关闭连接数",
+	BytesReceived:          "接收的字节数",
+	BytesSent:              "发送的字节数",
+	FramesReceived:         "按MsgID统计的接收帧数，未注册处理器的MsgID归为other",
+	FramesSent:             "按MsgID统计的发送帧数",
+	HandlerDuration:        "按MsgID统计的处理器耗时，包括数据流处理器",
+	SendQueueDepth:         "每批发送时发送队列中的消息数",
+	DecodeErrors:           "按原因统计的解码错误数",
+	ServerAccepted:         "服务端接受的连接数",
+	ServerRejected:         "按原因统计的服务端拒绝的连接数",
+	ClientDialErrors:       "客户端拨号失败次数",
+	ClientReconnects:       "客户端重连次数",
+	ClientConnectedSeconds: "客户端每次连接的持续时长",
+}
+
+// 直方图默认的分桶
+var (
+	DurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	DepthBuckets    = []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024}
+)
+
+var buckets = map[string][]float64{
+	SendQueueDepth:         DepthBuckets,
+	ClientConnectedSeconds: {1, 10, 60, 300, 1800, 3600, 21600, 86400},
+}
+
+// Discard 丢弃所有指标
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) IncCounter(string, float64, ...Label) {}
+func (discard) AddGauge(string, float64, ...Label)   {}
+func (discard) Observe(string, float64, ...Label)    {}
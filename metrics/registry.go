@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry 内存中的指标集合，实现 Sink，通过 Handler 以 Prometheus 文本格式输出
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+	help     map[string]string
+	buckets  map[string][]float64
+}
+
+type family struct {
+	kind   string
+	series map[string]*series
+}
+
+type series struct {
+	labels  []Label
+	value   float64
+	counts  []uint64 // 直方图各分桶的计数，不累计
+	sum     float64
+	count   uint64
+	buckets []float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+		help:     make(map[string]string),
+		buckets:  make(map[string][]float64),
+	}
+}
+
+// SetHelp 设置指标的说明
+func (r *Registry) SetHelp(name, help string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.help[name] = help
+}
+
+// SetBuckets 设置直方图的分桶，需在第一次记录之前设置
+func (r *Registry) SetBuckets(name string, buckets []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buckets[name] = slices.Sorted(slices.Values(buckets))
+}
+
+func (r *Registry) IncCounter(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, typeCounter, labels).value += value
+}
+
+func (r *Registry) AddGauge(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, typeGauge, labels).value += value
+}
+
+func (r *Registry) Observe(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.series(name, typeHistogram, labels)
+	if s.counts == nil {
+		s.buckets = r.bucketsOf(name)
+		s.counts = make([]uint64, len(s.buckets))
+	}
+	if i := sort.SearchFloat64s(s.buckets, value); i < len(s.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (r *Registry) bucketsOf(name string) []float64 {
+	if b, ok := r.buckets[name]; ok {
+		return b
+	}
+	if b, ok := buckets[name]; ok {
+		return b
+	}
+	return DurationBuckets
+}
+
+func (r *Registry) series(name, kind string, labels []Label) *series {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{
+			kind:   kind,
+			series: make(map[string]*series),
+		}
+		r.families[name] = f
+	}
+	key := labelString(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: slices.Clone(labels)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteTo 以 Prometheus 文本格式输出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	b := &strings.Builder{}
+	for _, name := range slices.Sorted(maps.Keys(r.families)) {
+		f := r.families[name]
+		h, ok := r.help[name]
+		if !ok {
+			h = help[name]
+		}
+		if h != "" {
+			fmt.Fprintf(b, "# HELP %s %s\n", name, escapeHelp(h))
+		}
+		fmt.Fprintf(b, "# TYPE %s %s\n", name, f.kind)
+		for _, key := range slices.Sorted(maps.Keys(f.series)) {
+			s := f.series[key]
+			if f.kind != typeHistogram {
+				fmt.Fprintf(b, "%s%s %s\n", name, key, formatFloat(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, le := range s.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(b, "%s_bucket%s %d\n", name, labelString(s.labels, Label{"le", formatFloat(le)}), cumulative)
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", name, labelString(s.labels, Label{"le", "+Inf"}), s.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", name, key, formatFloat(s.sum))
+			fmt.Fprintf(b, "%s_count%s %d\n", name, key, s.count)
+		}
+	}
+	r.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler 输出 Prometheus 文本格式的HTTP处理器
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+func labelString(labels []Label, extra ...Label) string {
+	if len(labels)+len(extra) == 0 {
+		return ""
+	}
+	b := &strings.Builder{}
+	b.WriteByte('{')
+	for i, l := range append(slices.Clone(labels), extra...) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var _ Sink = (*Registry)(nil)
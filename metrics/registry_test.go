@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+	r.SetHelp("test_counter_total", "计数\\器\n说明")
+	r.SetBuckets("test_duration_seconds", []float64{1, 0.1})
+	r.IncCounter("test_counter_total", 1, Label{"cause", `a"b\c`})
+	r.IncCounter("test_counter_total", 2, Label{"cause", `a"b\c`})
+	r.IncCounter("test_counter_total", 1, Label{"cause", "eof"})
+	r.AddGauge("test_gauge", 3)
+	r.AddGauge("test_gauge", -1)
+	r.Observe("test_duration_seconds", 0.05, Label{"msg_id", "1"})
+	r.Observe("test_duration_seconds", 0.5, Label{"msg_id", "1"})
+	r.Observe("test_duration_seconds", 5, Label{"msg_id", "1"})
+
+	var out strings.Builder
+	if _, err := r.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_counter_total 计数\\器\n说明
+# TYPE test_counter_total counter
+test_counter_total{cause="a\"b\\c"} 3
+test_counter_total{cause="eof"} 1
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{msg_id="1",le="0.1"} 1
+test_duration_seconds_bucket{msg_id="1",le="1"} 2
+test_duration_seconds_bucket{msg_id="1",le="+Inf"} 3
+test_duration_seconds_sum{msg_id="1"} 5.55
+test_duration_seconds_count{msg_id="1"} 3
+# TYPE test_gauge gauge
+test_gauge 2
+`
+	if out.String() != want {
+		t.Fatalf("输出:\n%s\n期望:\n%s", out.String(), want)
+	}
+}
+
+// 框架指标使用内置的说明与分桶
+func TestRegistryDefaults(t *testing.T) {
+	r := NewRegistry()
+	r.Observe(SendQueueDepth, 3)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	text := rec.Body.String()
+	for _, line := range []string{
+		"# HELP " + SendQueueDepth + " " + help[SendQueueDepth],
+		"# TYPE " + SendQueueDepth + " histogram",
+		SendQueueDepth + `_bucket{le="2"} 0`,
+		SendQueueDepth + `_bucket{le="4"} 1`,
+		SendQueueDepth + `_bucket{le="+Inf"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Fatalf("缺少 %q:\n%s", line, text)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrMsgID = errors.New("消息ID超出范围")
+
+// Codec 消息编解码器，负责消息在连接上的封帧(MarshalMessage)与拆帧(Unmarshal)
+// 同一个Codec会被多个连接并发使用，实现必须是无状态或并发安全的
+type Codec interface {
+	Unmarshal(conn io.Reader) (*Message, error)
+	MarshalMessage(conn io.Writer, message *Message) error
+}
+
+// MsgIDLimiter 消息ID范围受限的编解码器，MaxMsgID 为能编码的最大消息ID
+// 不能编码保留消息ID时，心跳、握手、压缩协商等依赖控制帧的功能不可用
+type MsgIDLimiter interface {
+	MaxMsgID() uint32
+}
+
+// DataLimiter 数据长度受限的编解码器，MaxDataLen 为单帧数据部分的最大长度
+type DataLimiter interface {
+	MaxDataLen() uint32
+}
+
+// SupportsReserved 编解码器能否编码保留消息ID
+func SupportsReserved(c Codec) bool {
+	l, ok := c.(MsgIDLimiter)
+	return !ok || l.MaxMsgID() >= ExtMsgID
+}
+
+// ChecksumCodec 支持帧校验和的编解码器，Checksum 返回带校验和的变体
+type ChecksumCodec interface {
+	Codec
+	Checksum() Codec
+}
+
+var (
+	_ ChecksumCodec = (*Decoder)(nil)
+
+	_ Codec = (*Decoder)(nil)
+	_ Codec = (*VarintCodec)(nil)
+	_ Codec = (*ShortIDCodec)(nil)
+
+	_ MsgIDLimiter = (*ShortIDCodec)(nil)
+
+	_ DataLimiter = (*Decoder)(nil)
+	_ DataLimiter = (*VarintCodec)(nil)
+	_ DataLimiter = (*ShortIDCodec)(nil)
+)
@@ -0,0 +1,106 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	for name, codec := range map[string]Codec{
+		"Decoder":      NewDecoder(0),
+		"Checksum":     NewDecoder(0).Checksum(),
+		"VarintCodec":  NewVarintCodec(0),
+		"ShortIDCodec": NewShortIDCodec(0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			messages := []*Message{
+				{MsgID: 1, Data: []byte("hello")},
+				{MsgID: 0xFFFF, Data: nil},
+				{MsgID: 2, Data: bytes.Repeat([]byte{0xAB}, int(MaxDataLen))},
+			}
+			for _, m := range messages {
+				if err := codec.MarshalMessage(buf, m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, want := range messages {
+				got, err := codec.Unmarshal(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.MsgID != want.MsgID || !bytes.Equal(got.Data, want.Data) {
+					t.Fatalf("收到 MsgID=%d %d字节，期望 MsgID=%d %d字节", got.MsgID, len(got.Data), want.MsgID, len(want.Data))
+				}
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("剩余 %d 字节未读取", buf.Len())
+			}
+		})
+	}
+}
+
+func TestCodecDataLimit(t *testing.T) {
+	for name, newCodec := range map[string]func(maxDataLen uint32) Codec{
+		"Decoder":      func(n uint32) Codec { return NewDecoder(n) },
+		"VarintCodec":  func(n uint32) Codec { return NewVarintCodec(n) },
+		"ShortIDCodec": func(n uint32) Codec { return NewShortIDCodec(n) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := newCodec(16)
+			if l := codec.(DataLimiter).MaxDataLen(); l != 16 {
+				t.Fatalf("MaxDataLen = %d，期望 16", l)
+			}
+			if l := newCodec(0).(DataLimiter).MaxDataLen(); l != MaxDataLen {
+				t.Fatalf("MaxDataLen = %d，期望 %d", l, MaxDataLen)
+			}
+			if err := codec.MarshalMessage(&bytes.Buffer{}, &Message{MsgID: 1, Data: make([]byte, 17)}); !errors.Is(err, ErrDataLength) {
+				t.Fatalf("err = %v，期望 ErrDataLength", err)
+			}
+
+			// 对端按更大的限制编码，本端解码时拒绝
+			buf := &bytes.Buffer{}
+			if err := newCodec(0).MarshalMessage(buf, &Message{MsgID: 1, Data: make([]byte, 17)}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := codec.Unmarshal(buf); !errors.Is(err, ErrDataLength) {
+				t.Fatalf("err = %v，期望 ErrDataLength", err)
+			}
+		})
+	}
+}
+
+func TestShortIDCodecMsgID(t *testing.T) {
+	codec := NewShortIDCodec(0)
+	if SupportsReserved(codec) {
+		t.Fatal("ShortIDCodec 不能编码保留消息ID")
+	}
+	if err := codec.MarshalMessage(&bytes.Buffer{}, &Message{MsgID: 0x10000}); !errors.Is(err, ErrMsgID) {
+		t.Fatalf("err = %v，期望 ErrMsgID", err)
+	}
+	if !SupportsReserved(NewVarintCodec(0)) {
+		t.Fatal("VarintCodec 应能编码保留消息ID")
+	}
+}
+
+// uvarint编码的长度字段按实际长度占用1到5字节
+func TestVarintCodecLength(t *testing.T) {
+	codec := NewVarintCodec(0)
+	for _, tt := range []struct {
+		n    int
+		size int
+	}{
+		{0, 4 + 1},
+		{127, 4 + 1 + 127},
+		{128, 4 + 2 + 128},
+	} {
+		buf := &bytes.Buffer{}
+		if err := codec.MarshalMessage(buf, &Message{MsgID: 1, Data: make([]byte, tt.n)}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != tt.size {
+			t.Fatalf("%d字节数据编码后 %d 字节，期望 %d 字节", tt.n, buf.Len(), tt.size)
+		}
+	}
+}
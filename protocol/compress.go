@@ -0,0 +1,166 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrCompressor = errors.New("不支持的压缩算法")
+
+// 内置压缩算法ID，自定义算法使用 CompressorCustom 及以上的ID
+const (
+	CompressorGzip uint8 = iota + 1
+	CompressorFlate
+	CompressorZlib
+	CompressorCustom uint8 = 128
+)
+
+// Compressor 消息数据压缩算法，需要并发安全
+// 压缩后的扩展帧数据格式: 1字节算法ID + 压缩数据
+type Compressor interface {
+	ID() uint8
+	Compress(data []byte) ([]byte, error)
+	// Decompress 解压数据，解压后超过limit字节时返回 ErrDataLength
+	Decompress(data []byte, limit uint32) ([]byte, error)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) ID() uint8 {
+	return CompressorGzip
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte, limit uint32) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimit(r, limit)
+}
+
+type flateCompressor struct{}
+
+func (flateCompressor) ID() uint8 {
+	return CompressorFlate
+}
+
+func (flateCompressor) Compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCompressor) Decompress(data []byte, limit uint32) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return readLimit(r, limit)
+}
+
+type zlibCompressor struct{}
+
+func (zlibCompressor) ID() uint8 {
+	return CompressorZlib
+}
+
+func (zlibCompressor) Compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (zlibCompressor) Decompress(data []byte, limit uint32) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimit(r, limit)
+}
+
+// readLimit 读取全部数据，最多读取limit+1字节，防止压缩炸弹
+func readLimit(r io.Reader, limit uint32) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(b)) > limit {
+		return nil, fmt.Errorf("%w 不得大于%d", ErrDataLength, limit)
+	}
+	return b, nil
+}
+
+var (
+	Gzip  Compressor = gzipCompressor{}
+	Flate Compressor = flateCompressor{}
+	Zlib  Compressor = zlibCompressor{}
+)
+
+// Compress 使用compressor压缩消息数据，设置 FlagCompressed 标志位
+// 压缩后不比原数据小时返回原消息
+func Compress(message *Message, compressor Compressor) (*Message, error) {
+	b, err := compressor.Compress(message.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(b)+1 >= len(message.Data) {
+		return message, nil
+	}
+	r := *message
+	r.Flags |= FlagCompressed
+	r.Data = append([]byte{compressor.ID()}, b...)
+	return &r, nil
+}
+
+// Decompress 解压带有 FlagCompressed 标志位的消息，lookup 根据算法ID查找压缩算法
+func Decompress(message *Message, limit uint32, lookup func(id uint8) Compressor) (*Message, error) {
+	if message.Flags&FlagCompressed == 0 {
+		return message, nil
+	}
+	if len(message.Data) < 1 {
+		return nil, ErrExtFrame
+	}
+	compressor := lookup(message.Data[0])
+	if compressor == nil {
+		return nil, fmt.Errorf("%w: %d", ErrCompressor, message.Data[0])
+	}
+	b, err := compressor.Decompress(message.Data[1:], limit)
+	if err != nil {
+		return nil, err
+	}
+	message.Flags &^= FlagCompressed
+	message.Data = b
+	return message, nil
+}
@@ -4,12 +4,14 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 )
 
 var (
-	ErrInvalidWrite = errors.New("invalid write result")
-	ErrDataLength   = errors.New("数据长度过大")
+	ErrInvalidWrite     = errors.New("invalid write result")
+	ErrDataLength       = errors.New("数据长度过大")
+	ErrChecksumMismatch = errors.New("帧校验和不匹配")
 )
 
 // 定义协议头各部分的长度
@@ -18,10 +20,17 @@ const (
 	DataSizeLen   = uint32(4) // 数据大小字段的长度，单位为字节
 	ReadLen       = HeaderDataLen + DataSizeLen
 	MaxDataLen    = uint32(8 * 1024)
+	ChecksumLen   = uint32(4) // 帧尾CRC32C校验和的长度
 )
 
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Decoder 默认编解码器: 4字节MsgID + 4字节数据长度(大端序) + 数据
+// 开启校验和时帧尾追加4字节CRC32C(大端序)，覆盖帧头与数据
 type Decoder struct {
 	maxDataLen uint32
+	checksum   bool
+	crc        *Decoder
 }
 
 func NewDecoder(maxDataLen uint32) *Decoder {
@@ -30,9 +39,23 @@ func NewDecoder(maxDataLen uint32) *Decoder {
 		maxDataLen = MaxDataLen
 	}
 	d.maxDataLen = maxDataLen
+	d.crc = &Decoder{
+		maxDataLen: maxDataLen,
+		checksum:   true,
+	}
+	d.crc.crc = d.crc
 	return d
 }
 
+// Checksum 返回带CRC32C校验和的编解码器，多次调用返回同一个实例
+func (d *Decoder) Checksum() Codec {
+	return d.crc
+}
+
+func (d *Decoder) MaxDataLen() uint32 {
+	return d.maxDataLen
+}
+
 func (d *Decoder) Unmarshal(conn io.Reader) (*Message, error) {
 	buf := make([]byte, ReadLen)
 	if _, err := io.ReadFull(conn, buf); err != nil {
@@ -44,10 +67,22 @@ func (d *Decoder) Unmarshal(conn io.Reader) (*Message, error) {
 	if dataSize > d.maxDataLen {
 		return nil, fmt.Errorf("%w 不得大于%d", ErrDataLength, d.maxDataLen)
 	}
-	buf = make([]byte, dataSize)
+	header := buf
+	if d.checksum {
+		buf = make([]byte, dataSize+ChecksumLen)
+	} else {
+		buf = make([]byte, dataSize)
+	}
 	if _, err := io.ReadFull(conn, buf); err != nil {
 		return nil, err
 	}
+	if d.checksum {
+		sum := crc32.Update(crc32.Checksum(header, castagnoli), castagnoli, buf[:dataSize])
+		if sum != binary.BigEndian.Uint32(buf[dataSize:]) {
+			return nil, ErrChecksumMismatch
+		}
+		buf = buf[:dataSize]
+	}
 	r := &Message{
 		MsgID: MsgID,
 		Data:  buf,
@@ -55,15 +90,28 @@ func (d *Decoder) Unmarshal(conn io.Reader) (*Message, error) {
 	return r, nil
 }
 
+// Marshal 按MsgID与数据封帧，等同于 MarshalMessage
 func (d *Decoder) Marshal(conn io.Writer, MsgID uint32, data []byte) error {
+	return d.MarshalMessage(conn, &Message{MsgID: MsgID, Data: data})
+}
+
+func (d *Decoder) MarshalMessage(conn io.Writer, message *Message) error {
+	MsgID, data := message.MsgID, message.Data
 	n := uint32(len(data))
 	if n > d.maxDataLen {
 		return fmt.Errorf("%w 不得大于%d", ErrDataLength, d.maxDataLen)
 	}
-	b := make([]byte, ReadLen+n)
+	size := ReadLen + n
+	if d.checksum {
+		size += ChecksumLen
+	}
+	b := make([]byte, size)
 	binary.BigEndian.PutUint32(b[0:HeaderDataLen], uint32(MsgID))
 	binary.BigEndian.PutUint32(b[HeaderDataLen:ReadLen], uint32(n))
 	copy(b[ReadLen:], data)
+	if d.checksum {
+		binary.BigEndian.PutUint32(b[ReadLen+n:], crc32.Checksum(b[:ReadLen+n], castagnoli))
+	}
 	_, err := conn.Write(b)
 	return err
 }
@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrExtFrame = errors.New("扩展帧格式错误")
+
+// 保留的消息ID，大于等于 ReservedMsgID 的消息ID由框架内部使用
+const (
+	ReservedMsgID = uint32(0xFFFFFF00)
+	ExtMsgID      = uint32(0xFFFFFFFF) // 扩展帧，数据部分携带扩展头部
+	PingMsgID     = uint32(0xFFFFFFFE) // 心跳请求
+	PongMsgID     = uint32(0xFFFFFFFD) // 心跳应答
+	GoAwayMsgID   = uint32(0xFFFFFFFC) // 对端即将关闭连接
+	StreamMsgID   = uint32(0xFFFFFFFB) // 数据流帧
+	CompressMsgID = uint32(0xFFFFFFFA) // 通告本端支持的压缩算法
+	ChecksumMsgID = uint32(0xFFFFFFF9) // 帧校验和协商
+	HelloMsgID    = uint32(0xFFFFFFF8) // 握手
+)
+
+// 扩展头部标志位
+const (
+	FlagRequest    uint8 = 1 << iota // 请求消息，对端需要应答
+	FlagReply                        // 应答消息
+	FlagError                        // 错误应答
+	FlagCompressed                   // 数据已压缩
+	FlagHeader                       // 携带头部
+)
+
+const (
+	extFlagsLen = 1
+	extSeqLen   = 4
+)
+
+// ExtSeqOverhead 携带关联ID的扩展帧比原始数据多出的长度，不含头部
+// 请求与应答按扩展帧发送，数据最多为 maxDataLen-ExtSeqOverhead 字节
+const ExtSeqOverhead = extFlagsLen + HeaderDataLen + extSeqLen
+
+// Pack 将带有扩展字段的消息封装为扩展帧，普通消息原样返回
+// 扩展帧数据格式: 1字节标志位 + 4字节MsgID + [4字节Seq] + [头部] + 数据
+func Pack(message *Message) (*Message, error) {
+	flags := message.Flags &^ FlagHeader
+	if len(message.Header) > 0 {
+		flags |= FlagHeader
+	}
+	if flags == 0 {
+		return message, nil
+	}
+	b := make([]byte, 0, extFlagsLen+HeaderDataLen+extSeqLen+uint32(len(message.Data)))
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, message.MsgID)
+	if message.HasSeq() {
+		b = binary.BigEndian.AppendUint32(b, message.Seq)
+	}
+	if flags&FlagHeader != 0 {
+		var err error
+		if b, err = appendHeader(b, message.Header); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, message.Data...)
+	return &Message{
+		MsgID: ExtMsgID,
+		Data:  b,
+	}, nil
+}
+
+// Unpack 解析扩展帧，普通消息原样返回
+func Unpack(message *Message) (*Message, error) {
+	if message.MsgID != ExtMsgID {
+		return message, nil
+	}
+	b := message.Data
+	if len(b) < extFlagsLen+int(HeaderDataLen) {
+		return nil, ErrExtFrame
+	}
+	r := &Message{
+		Flags: b[0],
+		MsgID: binary.BigEndian.Uint32(b[extFlagsLen:]),
+	}
+	b = b[extFlagsLen+HeaderDataLen:]
+	if r.HasSeq() {
+		if len(b) < extSeqLen {
+			return nil, ErrExtFrame
+		}
+		r.Seq = binary.BigEndian.Uint32(b)
+		b = b[extSeqLen:]
+	}
+	if r.Flags&FlagHeader != 0 {
+		header, rest, err := readHeader(b)
+		if err != nil {
+			return nil, err
+		}
+		r.Header, b = header, rest
+	}
+	r.Data = b
+	return r, nil
+}
@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"slices"
+)
+
+var ErrHeader = errors.New("消息头部格式错误")
+
+// 头部各字段长度的上限
+const (
+	MaxHeaderKeyLen   = 0xFF
+	MaxHeaderValueLen = 0xFFFF
+	MaxHeaderLen      = 0xFFFF
+)
+
+// Header 消息头部，随扩展帧传输的键值对
+// 实现 Get/Set/Keys，可以直接作为 OpenTelemetry 风格的文本载体使用
+type Header map[string]string
+
+func (h Header) Get(key string) string {
+	return h[key]
+}
+
+func (h Header) Set(key, value string) {
+	h[key] = value
+}
+
+func (h Header) Keys() []string {
+	return slices.Collect(maps.Keys(h))
+}
+
+// Size 头部编码后的长度，不含2字节总长度
+func (h Header) Size() int {
+	n := 0
+	for k, v := range h {
+		n += 1 + len(k) + 2 + len(v)
+	}
+	return n
+}
+
+// appendHeader 编码头部: 2字节总长度 + 若干个(1字节键长度 + 键 + 2字节值长度 + 值)，按键排序
+func appendHeader(b []byte, h Header) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0)
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		v := h[k]
+		if len(k) == 0 || len(k) > MaxHeaderKeyLen || len(v) > MaxHeaderValueLen {
+			return nil, ErrHeader
+		}
+		b = append(b, byte(len(k)))
+		b = append(b, k...)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(v)))
+		b = append(b, v...)
+	}
+	n := len(b) - start - 2
+	if n > MaxHeaderLen {
+		return nil, ErrHeader
+	}
+	binary.BigEndian.PutUint16(b[start:], uint16(n))
+	return b, nil
+}
+
+// readHeader 解码头部，返回头部与剩余数据
+func readHeader(b []byte) (Header, []byte, error) {
+	if len(b) < 2 {
+		return nil, nil, ErrHeader
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < n {
+		return nil, nil, ErrHeader
+	}
+	section, rest := b[:n], b[n:]
+	h := make(Header)
+	for len(section) > 0 {
+		kl := int(section[0])
+		if kl == 0 || len(section) < 1+kl+2 {
+			return nil, nil, ErrHeader
+		}
+		k := string(section[1 : 1+kl])
+		section = section[1+kl:]
+		vl := int(binary.BigEndian.Uint16(section))
+		if len(section) < 2+vl {
+			return nil, nil, ErrHeader
+		}
+		h[k] = string(section[2 : 2+vl])
+		section = section[2+vl:]
+	}
+	return h, rest, nil
+}
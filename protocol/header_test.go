@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	header := Header{
+		"traceparent":                        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"empty":                              "",
+		strings.Repeat("k", MaxHeaderKeyLen): "v",
+	}
+	packed, err := Pack(&Message{MsgID: 7, Flags: FlagRequest, Seq: 3, Header: header, Data: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packed.MsgID != ExtMsgID {
+		t.Fatalf("MsgID = %d，期望 ExtMsgID", packed.MsgID)
+	}
+	got, err := Unpack(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MsgID != 7 || got.Seq != 3 || got.Flags != FlagRequest|FlagHeader || string(got.Data) != "hello" {
+		t.Fatalf("收到 %+v", got)
+	}
+	if !maps.Equal(got.Header, header) {
+		t.Fatalf("Header = %v，期望 %v", got.Header, header)
+	}
+}
+
+func TestHeaderMalformed(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"缺少总长度":   {0},
+		"总长度超出数据": {0, 10, 3, 'k', 'e', 'y'},
+		"键被截断":    {0, 3, 5, 'k', 'e'},
+		"键长度为0":   {0, 3, 0, 0, 0},
+		"值被截断":    {0, 8, 3, 'k', 'e', 'y', 0, 5, 'v', 'a'},
+		"值长度超出头部": {0, 8, 3, 'k', 'e', 'y', 0, 9, 'v', 'a', 'l', 'u', 'e', 'x', 'x', 'x'},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := readHeader(b); !errors.Is(err, ErrHeader) {
+				t.Fatalf("err = %v，期望 ErrHeader", err)
+			}
+		})
+	}
+	if _, err := appendHeader(nil, Header{"": "v"}); !errors.Is(err, ErrHeader) {
+		t.Fatalf("空键 err = %v，期望 ErrHeader", err)
+	}
+	if _, err := appendHeader(nil, Header{strings.Repeat("k", MaxHeaderKeyLen+1): "v"}); !errors.Is(err, ErrHeader) {
+		t.Fatalf("超长键 err = %v，期望 ErrHeader", err)
+	}
+}
@@ -1,6 +1,14 @@
 package protocol
 
 type Message struct {
-	MsgID uint32
-	Data  []byte
+	MsgID  uint32
+	Data   []byte
+	Flags  uint8  // 扩展头部标志位，为0时按普通帧发送
+	Seq    uint32 // 请求/应答的关联ID
+	Header Header // 消息头部，不为空时按扩展帧发送
+}
+
+// HasSeq 是否携带关联ID
+func (m *Message) HasSeq() bool {
+	return m.Flags&(FlagRequest|FlagReply) != 0
 }
@@ -0,0 +1,66 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const ShortIDLen = uint32(2) // 短消息ID字段的长度
+
+// ShortIDCodec 2字节MsgID + 4字节数据长度(大端序) + 数据，用于兼容旧设备
+type ShortIDCodec struct {
+	maxDataLen uint32
+}
+
+func NewShortIDCodec(maxDataLen uint32) *ShortIDCodec {
+	if maxDataLen == 0 {
+		maxDataLen = MaxDataLen
+	}
+	return &ShortIDCodec{maxDataLen: maxDataLen}
+}
+
+// MaxMsgID 2字节MsgID，不能编码保留消息ID
+func (d *ShortIDCodec) MaxMsgID() uint32 {
+	return 0xFFFF
+}
+
+func (d *ShortIDCodec) MaxDataLen() uint32 {
+	return d.maxDataLen
+}
+
+func (d *ShortIDCodec) Unmarshal(conn io.Reader) (*Message, error) {
+	buf := make([]byte, ShortIDLen+DataSizeLen)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	MsgID := uint32(binary.BigEndian.Uint16(buf[0:ShortIDLen]))
+	dataSize := binary.BigEndian.Uint32(buf[ShortIDLen:])
+	if dataSize > d.maxDataLen {
+		return nil, fmt.Errorf("%w 不得大于%d", ErrDataLength, d.maxDataLen)
+	}
+	buf = make([]byte, dataSize)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return &Message{
+		MsgID: MsgID,
+		Data:  buf,
+	}, nil
+}
+
+func (d *ShortIDCodec) MarshalMessage(conn io.Writer, message *Message) error {
+	if message.MsgID > 0xFFFF {
+		return fmt.Errorf("%w 不得大于%d", ErrMsgID, 0xFFFF)
+	}
+	n := uint32(len(message.Data))
+	if n > d.maxDataLen {
+		return fmt.Errorf("%w 不得大于%d", ErrDataLength, d.maxDataLen)
+	}
+	b := make([]byte, ShortIDLen+DataSizeLen+n)
+	binary.BigEndian.PutUint16(b[0:ShortIDLen], uint16(message.MsgID))
+	binary.BigEndian.PutUint32(b[ShortIDLen:ShortIDLen+DataSizeLen], n)
+	copy(b[ShortIDLen+DataSizeLen:], message.Data)
+	_, err := conn.Write(b)
+	return err
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// VarintCodec 4字节MsgID(大端序) + uvarint编码的数据长度 + 数据
+type VarintCodec struct {
+	maxDataLen uint32
+}
+
+func NewVarintCodec(maxDataLen uint32) *VarintCodec {
+	if maxDataLen == 0 {
+		maxDataLen = MaxDataLen
+	}
+	return &VarintCodec{maxDataLen: maxDataLen}
+}
+
+func (d *VarintCodec) MaxDataLen() uint32 {
+	return d.maxDataLen
+}
+
+func (d *VarintCodec) Unmarshal(conn io.Reader) (*Message, error) {
+	buf := make([]byte, HeaderDataLen)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	MsgID := binary.BigEndian.Uint32(buf)
+
+	dataSize, err := binary.ReadUvarint(byteReader{conn})
+	if err != nil {
+		return nil, err
+	}
+	if dataSize > uint64(d.maxDataLen) {
+		return nil, fmt.Errorf("%w 不得大于%d", ErrDataLength, d.maxDataLen)
+	}
+	buf = make([]byte, dataSize)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return &Message{
+		MsgID: MsgID,
+		Data:  buf,
+	}, nil
+}
+
+func (d *VarintCodec) MarshalMessage(conn io.Writer, message *Message) error {
+	n := uint32(len(message.Data))
+	if n > d.maxDataLen {
+		return fmt.Errorf("%w 不得大于%d", ErrDataLength, d.maxDataLen)
+	}
+	b := make([]byte, HeaderDataLen, HeaderDataLen+binary.MaxVarintLen32+n)
+	binary.BigEndian.PutUint32(b, message.MsgID)
+	b = binary.AppendUvarint(b, uint64(n))
+	b = append(b, message.Data...)
+	_, err := conn.Write(b)
+	return err
+}
+
+// byteReader 逐字节读取，避免在连接上预读超出当前帧的数据
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(b.r, buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// Broadcast 向所有满足filter的连接发送消息，filter为nil时发送给所有连接
+// 消息只编码一次，放入各连接的发送队列后立即返回，不等待写入，慢连接不会阻塞广播
+// 返回入队失败的连接ID及错误，如发送队列已满时的 connection.ErrQueueFull
+func (m *Server) Broadcast(MsgID uint32, Data []byte, filter func(conn *connection.Connection) bool) map[uint64]error {
+	return m.BroadcastContext(context.Background(), MsgID, Data, filter)
+}
+
+// BroadcastContext 同 Broadcast，ctx 传给出站拦截器并用于注入链路上下文
+func (m *Server) BroadcastContext(ctx context.Context, MsgID uint32, Data []byte, filter func(conn *connection.Connection) bool) map[uint64]error {
+	conns := make([]*connection.Connection, 0, m.connCount.Load())
+	m.Range(func(conn *connection.Connection) bool {
+		if filter == nil || filter(conn) {
+			conns = append(conns, conn)
+		}
+		return true
+	})
+	return m.fanout(ctx, conns, MsgID, Data)
+}
+
+// fanout 将同一条预编码消息放入多个连接的发送队列，不等待写入完成
+func (m *Server) fanout(ctx context.Context, conns []*connection.Connection, MsgID uint32, Data []byte) map[uint64]error {
+	errs := make(map[uint64]error)
+	pm, err := connection.NewPreparedMessage(MsgID, Data)
+	if err != nil {
+		for _, conn := range conns {
+			errs[conn.ID()] = err
+		}
+		return errs
+	}
+
+	for _, conn := range conns {
+		body := conn.SendPreparedAsync(ctx, pm)
+		select {
+		case <-body.Done():
+			// 入队失败、被拦截或已经写入完成
+			if err := body.Err(); err != nil {
+				errs[conn.ID()] = err
+			}
+		default:
+		}
+	}
+	return errs
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// newReceiver 建立一个把MsgID为1的消息转发到返回通道的客户端
+func newReceiver(t *testing.T, l *pipeListener, action *testAction) (<-chan []byte, *connection.HandlerManager, *connection.Connection) {
+	t.Helper()
+	got := make(chan []byte, 4)
+	client, conn := dial(t, l, action, map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		got <- r.GetData()
+	})})
+	return got, client, conn
+}
+
+func TestBroadcast(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	var received []<-chan []byte
+	var conns []*connection.Connection
+	for range 3 {
+		got, _, conn := newReceiver(t, l, action)
+		received = append(received, got)
+		conns = append(conns, conn)
+	}
+
+	if errs := m.Broadcast(1, []byte("all"), nil); len(errs) != 0 {
+		t.Fatalf("errs = %v", errs)
+	}
+	for _, got := range received {
+		if data := recv(t, got); string(data) != "all" {
+			t.Fatalf("data = %q", data)
+		}
+	}
+
+	// filter 排除的连接收不到消息
+	excluded := conns[0].ID()
+	errs := m.Broadcast(1, []byte("some"), func(conn *connection.Connection) bool {
+		return conn.ID() != excluded
+	})
+	if len(errs) != 0 {
+		t.Fatalf("errs = %v", errs)
+	}
+	for _, got := range received[1:] {
+		if data := recv(t, got); string(data) != "some" {
+			t.Fatalf("data = %q", data)
+		}
+	}
+	select {
+	case data := <-received[0]:
+		t.Fatalf("被排除的连接收到 %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// 保留的消息ID对每个连接都返回错误
+func TestBroadcastReservedMsgID(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	_, _, conn := newReceiver(t, l, action)
+	errs := m.Broadcast(0xFFFFFFFF, nil, nil)
+	if err, ok := errs[conn.ID()]; !ok || err != connection.ErrReservedMsgID {
+		t.Fatalf("errs = %v，期望 connection.ErrReservedMsgID", errs)
+	}
+}
+
+// 不读取数据的连接不会阻塞广播，发送队列满后返回 ErrQueueFull
+func TestBroadcastSlowPeer(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	a, b := net.Pipe()
+	defer b.Close()
+	l.conns <- a
+	stalled := recv(t, action.begin)
+	dial(t, l, action, map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {})})
+
+	done := make(chan error, 1)
+	go func() {
+		for range connection.DefaultSendQueueSize + 2 {
+			if err := m.Broadcast(1, []byte("x"), nil)[stalled.ID()]; err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	if err := recv(t, done); !errors.Is(err, connection.ErrQueueFull) {
+		t.Fatalf("err = %v，期望 connection.ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// groups 分组成员关系，连接断开时自动退出所有分组
+type groups struct {
+	mu      sync.RWMutex
+	members map[string]map[uint64]*connection.Connection
+	joined  map[uint64]map[string]struct{}
+}
+
+// Join 将连接加入分组
+func (m *Server) Join(group string, conn *connection.Connection) error {
+	m.groups.mu.Lock()
+	defer m.groups.mu.Unlock()
+	// 在锁内检查连接是否仍然存活，避免与断开时的清理交错留下失效成员
+	if _, ok := m.conns.Load(conn.ID()); !ok {
+		return ErrConnNotFound
+	}
+	if m.groups.members == nil {
+		m.groups.members = make(map[string]map[uint64]*connection.Connection)
+		m.groups.joined = make(map[uint64]map[string]struct{})
+	}
+	members, ok := m.groups.members[group]
+	if !ok {
+		members = make(map[uint64]*connection.Connection)
+		m.groups.members[group] = members
+	}
+	members[conn.ID()] = conn
+	joined, ok := m.groups.joined[conn.ID()]
+	if !ok {
+		joined = make(map[string]struct{})
+		m.groups.joined[conn.ID()] = joined
+	}
+	joined[group] = struct{}{}
+	return nil
+}
+
+// Leave 将连接移出分组
+func (m *Server) Leave(group string, conn *connection.Connection) {
+	m.groups.mu.Lock()
+	defer m.groups.mu.Unlock()
+	m.leave(group, conn.ID())
+}
+
+func (m *Server) leave(group string, id uint64) {
+	if members, ok := m.groups.members[group]; ok {
+		delete(members, id)
+		if len(members) == 0 {
+			delete(m.groups.members, group)
+		}
+	}
+	if joined, ok := m.groups.joined[id]; ok {
+		delete(joined, group)
+		if len(joined) == 0 {
+			delete(m.groups.joined, id)
+		}
+	}
+}
+
+// leaveAll 连接断开时退出所有分组
+func (m *Server) leaveAll(id uint64) {
+	m.groups.mu.Lock()
+	defer m.groups.mu.Unlock()
+	for group := range m.groups.joined[id] {
+		m.leave(group, id)
+	}
+}
+
+// GroupMembers 分组内的所有连接
+func (m *Server) GroupMembers(group string) []*connection.Connection {
+	m.groups.mu.RLock()
+	defer m.groups.mu.RUnlock()
+	conns := make([]*connection.Connection, 0, len(m.groups.members[group]))
+	for _, conn := range m.groups.members[group] {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// SendToGroup 向分组内所有连接发送消息，与 Broadcast 一样入队后立即返回，返回入队失败的连接ID及错误
+func (m *Server) SendToGroup(group string, MsgID uint32, Data []byte) map[uint64]error {
+	return m.SendToGroupContext(context.Background(), group, MsgID, Data)
+}
+
+// SendToGroupContext 同 SendToGroup，ctx 传给出站拦截器并用于注入链路上下文
+func (m *Server) SendToGroupContext(ctx context.Context, group string, MsgID uint32, Data []byte) map[uint64]error {
+	return m.fanout(ctx, m.GroupMembers(group), MsgID, Data)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSendToGroup(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	inGot, _, in := newReceiver(t, l, action)
+	outGot, _, _ := newReceiver(t, l, action)
+
+	if err := m.Join("room", in); err != nil {
+		t.Fatal(err)
+	}
+	if errs := m.SendToGroup("room", 1, []byte("hi")); len(errs) != 0 {
+		t.Fatalf("errs = %v", errs)
+	}
+	if data := recv(t, inGot); string(data) != "hi" {
+		t.Fatalf("data = %q", data)
+	}
+	if len(outGot) != 0 {
+		t.Fatal("分组外的连接收到消息")
+	}
+
+	m.Leave("room", in)
+	if n := len(m.GroupMembers("room")); n != 0 {
+		t.Fatalf("退出后分组成员数 = %d", n)
+	}
+}
+
+// 连接断开时自动退出所有分组，之后不能再加入分组
+func TestGroupLeaveOnDisconnect(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	_, client, conn := newReceiver(t, l, action)
+	_, _, other := newReceiver(t, l, action)
+	for _, group := range []string{"a", "b"} {
+		if err := m.Join(group, conn); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Join(group, other); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	<-client.Stop()
+	recv(t, action.errs)
+	eventually(t, func() bool {
+		m.groups.mu.RLock()
+		defer m.groups.mu.RUnlock()
+		_, joined := m.groups.joined[conn.ID()]
+		return !joined
+	})
+	for _, group := range []string{"a", "b"} {
+		members := m.GroupMembers(group)
+		if len(members) != 1 || members[0] != other {
+			t.Fatalf("分组 %s 成员 = %v", group, members)
+		}
+	}
+	if err := m.Join("a", conn); !errors.Is(err, ErrConnNotFound) {
+		t.Fatalf("err = %v，期望 ErrConnNotFound", err)
+	}
+}
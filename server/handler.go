@@ -2,33 +2,46 @@ package server
 
 import (
 	"context"
+	"log/slog"
 	"sync"
 
 	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/internal/logging"
+	"github.com/s84662355/simple-message/metrics"
 )
 
 func (m *Server) accept(ctx context.Context) {
 	wg := &sync.WaitGroup{}
 	defer wg.Wait()
-	defer m.cancel()
+	defer func() {
+		// 优雅关闭时由 Shutdown 负责关闭连接
+		if !m.shutdown.Load() {
+			m.cancel()
+		}
+	}()
 	for m.isRun.Load() {
 		// 接受客户端的连接
 		conn, data, err := m.listener.Accept()
 		if err != nil {
+			if m.isRun.Load() {
+				m.logger.Error("接受连接失败", slog.Any("error", err))
+			}
 			return
 		}
 
-		if m.connCount.Add(1) > m.maxConnCount {
-			m.connCount.Add(-1)
-			conn.Close()
+		release, err := m.admit(conn)
+		if err != nil {
+			m.reject(ctx, conn, err)
 			continue
 		}
+		m.metrics.IncCounter(metrics.ServerAccepted, 1)
+		m.logger.Debug("接受连接", logging.Addr("remote_addr", remoteAddr(conn)))
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer conn.Close()
-			defer m.connCount.Add(-1)
+			defer release()
 			m.handlerTcpConn(ctx, conn, data)
 		}()
 
@@ -36,16 +49,29 @@ func (m *Server) accept(ctx context.Context) {
 }
 
 func (m *Server) handlerTcpConn(ctx context.Context, conn connection.Conn, data any) {
+	registered := make(chan struct{})
 	handlerManager := connection.NewHandlerManager(
 		conn,
 		m.handler,
 		m.maxDataLen,
-		m.action.ConnectedBegin,
+		func(ctx context.Context, conn *connection.Connection) {
+			// 保证 ConnectedBegin 中可以通过 GetConnection 查到当前连接
+			<-registered
+			m.action.ConnectedBegin(ctx, conn)
+		},
 		data,
+		m.getConnOptions()...,
 	)
+	id := handlerManager.GetConnection().ID()
+	m.conns.Store(id, handlerManager)
+	close(registered)
 	defer func() {
 		<-handlerManager.Stop()
-		m.action.ConnErr(ctx, handlerManager.GetConnection(), handlerManager.Err())
+		m.conns.Delete(id)
+		m.leaveAll(id)
+		handlerManager.Protect(0, func() {
+			m.action.ConnErr(ctx, handlerManager.GetConnection(), handlerManager.Err())
+		})
 	}()
 
 	select {
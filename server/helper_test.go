@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// pipeListener 通过 net.Pipe 建立连接的监听器
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *pipeListener) Accept() (connection.Conn, any, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil, nil
+	case <-l.done:
+		return nil, nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+// testAction 记录服务端建立的连接与连接错误
+type testAction struct {
+	begin chan *connection.Connection
+	errs  chan error
+}
+
+func newTestAction() *testAction {
+	return &testAction{
+		begin: make(chan *connection.Connection, 16),
+		errs:  make(chan error, 16),
+	}
+}
+
+func (a *testAction) ConnectedBegin(ctx context.Context, conn *connection.Connection) {
+	a.begin <- conn
+	<-ctx.Done()
+}
+
+func (a *testAction) ConnErr(ctx context.Context, conn *connection.Connection, err error) {
+	a.errs <- err
+}
+
+// newTestServer 启动使用 pipeListener 的服务端
+func newTestServer(t *testing.T, handler map[uint32]connection.Handler, opts ...Option) (*Server, *pipeListener, *testAction) {
+	t.Helper()
+	l := newPipeListener()
+	action := newTestAction()
+	m := NewServer(l, handler, 0, 100, action, opts...)
+	m.Start(1)
+	t.Cleanup(func() {
+		if done := m.Stop(); done != nil {
+			<-done
+		}
+	})
+	return m, l, action
+}
+
+// dial 建立一个客户端连接，返回客户端与对应的服务端连接
+func dial(t *testing.T, l *pipeListener, action *testAction, handler map[uint32]connection.Handler) (*connection.HandlerManager, *connection.Connection) {
+	t.Helper()
+	a, b := net.Pipe()
+	select {
+	case l.conns <- a:
+	case <-time.After(2 * time.Second):
+		t.Fatal("服务端未接受连接")
+	}
+	client := connection.NewHandlerManager(b, handler, 0, func(ctx context.Context, conn *connection.Connection) {
+		<-ctx.Done()
+	}, nil)
+	t.Cleanup(func() { <-client.Stop() })
+	return client, recv(t, action.begin)
+}
+
+// eventually 轮询直到cond成立，超时则失败
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("条件未满足")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// recv 从通道接收一个值，超时则失败
+func recv[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待超时")
+		panic("unreachable")
+	}
+}
+
+// waitErr 等待客户端连接结束并返回其错误
+func waitErr(t *testing.T, client *connection.HandlerManager) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- client.Err() }()
+	return recv(t, done)
+}
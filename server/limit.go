@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/internal/logging"
+	"github.com/s84662355/simple-message/internal/ratelimit"
+	"github.com/s84662355/simple-message/metrics"
+)
+
+// 拒绝连接的原因
+var (
+	ErrTooManyConns      = errors.New("连接数超过上限")
+	ErrTooManyConnsPerIP = errors.New("单个IP的连接数超过上限")
+	ErrAcceptRate        = errors.New("新建连接速率超过上限")
+	ErrDenied            = errors.New("IP不在允许范围内")
+)
+
+// RejectAction Action 可以选择实现该接口，在连接被拒绝时回调，用于记录和告警
+// 在accept协程中调用，不应阻塞
+type RejectAction interface {
+	ConnRejected(ctx context.Context, remoteAddr net.Addr, reason error)
+}
+
+type limiter struct {
+	perIP  int
+	bucket *ratelimit.Bucket
+	allow  []netip.Prefix
+	deny   []netip.Prefix
+	mu     sync.Mutex
+	counts map[netip.Addr]int
+}
+
+// remoteAddr 获取连接的对端地址，无法获取时返回nil
+func remoteAddr(conn connection.Conn) net.Addr {
+	if c, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return c.RemoteAddr()
+	}
+	return nil
+}
+
+// remoteIP 获取连接的对端IP，无法获取时返回零值
+func remoteIP(conn connection.Conn) netip.Addr {
+	remote := remoteAddr(conn)
+	if remote == nil {
+		return netip.Addr{}
+	}
+	if addr, ok := remote.(*net.TCPAddr); ok {
+		ip, _ := netip.AddrFromSlice(addr.IP)
+		return ip.Unmap()
+	}
+	addrPort, err := netip.ParseAddrPort(remote.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// admit 检查是否接受连接，接受时返回需要在连接结束后调用的释放函数
+// 无法获取对端IP时(如Unix域套接字)，配置了允许列表则拒绝，否则只检查速率和总连接数
+func (m *Server) admit(conn connection.Conn) (func(), error) {
+	l := &m.limiter
+	ip := remoteIP(conn)
+	if ip.IsValid() {
+		if contains(l.deny, ip) || len(l.allow) > 0 && !contains(l.allow, ip) {
+			return nil, ErrDenied
+		}
+	} else if len(l.allow) > 0 {
+		return nil, ErrDenied
+	}
+	if m.connCount.Add(1) > m.maxConnCount {
+		m.connCount.Add(-1)
+		return nil, ErrTooManyConns
+	}
+	release := func() { m.connCount.Add(-1) }
+	if l.perIP > 0 && ip.IsValid() {
+		l.mu.Lock()
+		if l.counts[ip] >= l.perIP {
+			l.mu.Unlock()
+			release()
+			return nil, ErrTooManyConnsPerIP
+		}
+		if l.counts == nil {
+			l.counts = make(map[netip.Addr]int)
+		}
+		l.counts[ip]++
+		l.mu.Unlock()
+		release = func() {
+			m.connCount.Add(-1)
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.counts[ip]--; l.counts[ip] <= 0 {
+				delete(l.counts, ip)
+			}
+		}
+	}
+	// 最后取令牌，因连接数被拒绝的连接不消耗令牌
+	if l.bucket != nil && !l.bucket.Allow() {
+		release()
+		return nil, ErrAcceptRate
+	}
+	return release, nil
+}
+
+// rejectReason 拒绝原因的指标标签
+func rejectReason(reason error) string {
+	switch {
+	case errors.Is(reason, ErrTooManyConns):
+		return "max_conns"
+	case errors.Is(reason, ErrTooManyConnsPerIP):
+		return "max_conns_per_ip"
+	case errors.Is(reason, ErrAcceptRate):
+		return "accept_rate"
+	case errors.Is(reason, ErrDenied):
+		return "denied"
+	}
+	return "other"
+}
+
+func (m *Server) reject(ctx context.Context, conn connection.Conn, reason error) {
+	conn.Close()
+	m.metrics.IncCounter(metrics.ServerRejected, 1, metrics.Label{Name: "reason", Value: rejectReason(reason)})
+	m.logger.Warn("拒绝连接", logging.Addr("remote_addr", remoteAddr(conn)), slog.Any("reason", reason))
+	action, ok := m.action.(RejectAction)
+	if !ok {
+		return
+	}
+	action.ConnRejected(ctx, remoteAddr(conn), reason)
+}
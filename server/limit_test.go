@@ -0,0 +1,135 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// addrConn 指定对端地址的连接
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func newAddrConn(t *testing.T, remote net.Addr) *addrConn {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return &addrConn{Conn: a, remote: remote}
+}
+
+func tcpAddr(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}
+}
+
+func TestAdmitAllowList(t *testing.T) {
+	m := NewServer(nil, nil, 0, 10, nil, WithAllowList(netip.MustParsePrefix("10.0.0.0/8")))
+	for _, tt := range []struct {
+		remote net.Addr
+		err    error
+	}{
+		{tcpAddr("10.1.2.3"), nil},
+		{tcpAddr("192.168.1.1"), ErrDenied},
+		// 无法获取IP的连接不能绕过允许列表
+		{&net.UnixAddr{Name: "/tmp/test.sock", Net: "unix"}, ErrDenied},
+		{nil, ErrDenied},
+	} {
+		release, err := m.admit(newAddrConn(t, tt.remote))
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%v: err = %v，期望 %v", tt.remote, err, tt.err)
+		}
+		if release != nil {
+			release()
+		}
+	}
+}
+
+func TestAdmitDenyList(t *testing.T) {
+	m := NewServer(nil, nil, 0, 10, nil,
+		WithAllowList(netip.MustParsePrefix("10.0.0.0/8")),
+		WithDenyList(netip.MustParsePrefix("10.0.0.0/16")),
+	)
+	if _, err := m.admit(newAddrConn(t, tcpAddr("10.0.1.1"))); !errors.Is(err, ErrDenied) {
+		t.Fatalf("err = %v，期望 ErrDenied", err)
+	}
+
+	// 只配置拒绝列表时，无法获取IP的连接照常接受
+	m = NewServer(nil, nil, 0, 10, nil, WithDenyList(netip.MustParsePrefix("10.0.0.0/8")))
+	release, err := m.admit(newAddrConn(t, &net.UnixAddr{Name: "/tmp/test.sock", Net: "unix"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestAdmitMaxConnsPerIP(t *testing.T) {
+	m := NewServer(nil, nil, 0, 10, nil, WithMaxConnsPerIP(1))
+	release, err := m.admit(newAddrConn(t, tcpAddr("10.0.0.1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.admit(newAddrConn(t, tcpAddr("10.0.0.1"))); !errors.Is(err, ErrTooManyConnsPerIP) {
+		t.Fatalf("err = %v，期望 ErrTooManyConnsPerIP", err)
+	}
+	if _, err := m.admit(newAddrConn(t, tcpAddr("10.0.0.2"))); err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, err := m.admit(newAddrConn(t, tcpAddr("10.0.0.1"))); err != nil {
+		t.Fatalf("释放后 err = %v", err)
+	}
+}
+
+func TestAdmitMaxConns(t *testing.T) {
+	m := NewServer(nil, nil, 0, 1, nil)
+	release, err := m.admit(newAddrConn(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.admit(newAddrConn(t, nil)); !errors.Is(err, ErrTooManyConns) {
+		t.Fatalf("err = %v，期望 ErrTooManyConns", err)
+	}
+	release()
+	if _, err := m.admit(newAddrConn(t, nil)); err != nil {
+		t.Fatalf("释放后 err = %v", err)
+	}
+}
+
+// 因连接数被拒绝的连接不消耗速率令牌
+func TestAdmitRateAfterConnLimit(t *testing.T) {
+	m := NewServer(nil, nil, 0, 1, nil, WithAcceptRate(0.001, 1))
+	release, err := m.admit(newAddrConn(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, err := m.admit(newAddrConn(t, nil)); !errors.Is(err, ErrAcceptRate) {
+		t.Fatalf("err = %v，期望 ErrAcceptRate", err)
+	}
+	if m.connCount.Load() != 0 {
+		t.Fatalf("connCount = %d，期望 0", m.connCount.Load())
+	}
+
+	m = NewServer(nil, nil, 0, 1, nil, WithAcceptRate(0.001, 2))
+	if release, err = m.admit(newAddrConn(t, nil)); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := m.admit(newAddrConn(t, nil)); !errors.Is(err, ErrTooManyConns) {
+			t.Fatalf("err = %v，期望 ErrTooManyConns", err)
+		}
+	}
+	release()
+	if _, err := m.admit(newAddrConn(t, nil)); err != nil {
+		t.Fatalf("释放后 err = %v", err)
+	}
+}
@@ -1,10 +1,187 @@
 package server
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/s84662355/simple-message/connection"
 )
 
+// ErrAddrInUse Unix域套接字正在被其它进程监听
+var ErrAddrInUse = errors.New("地址已被占用")
+
 type Listener interface {
 	Accept() (connection.Conn, any, error)
 	Close() error
 }
+
+// 临时错误重试的等待时长，与 net/http 一致
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// NetListener 将 net.Listener 适配为 Listener，临时错误按指数退避重试
+type NetListener struct {
+	listener net.Listener
+}
+
+func NewNetListener(listener net.Listener) *NetListener {
+	return &NetListener{listener: listener}
+}
+
+func (l *NetListener) Accept() (connection.Conn, any, error) {
+	var delay time.Duration
+	for {
+		conn, err := l.listener.Accept()
+		if err == nil {
+			return conn, nil, nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Temporary() {
+			if delay == 0 {
+				delay = minAcceptDelay
+			} else {
+				delay = min(delay*2, maxAcceptDelay)
+			}
+			time.Sleep(delay)
+			continue
+		}
+		return nil, nil, err
+	}
+}
+
+func (l *NetListener) Close() error {
+	return l.listener.Close()
+}
+
+// Addr 实际监听的地址
+func (l *NetListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// ListenTCP 监听TCP地址
+func ListenTCP(address string) (*NetListener, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return NewNetListener(l), nil
+}
+
+// ListenUnix 监听Unix域套接字，path 已存在且是遗留的套接字文件时先删除
+// 通过连接探测套接字是否仍在使用，仍有进程监听时返回 ErrAddrInUse
+func ListenUnix(path string) (*NetListener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrAddrInUse, path)
+		}
+		// 只有连接被拒绝才说明没有进程监听，其它错误(如无权限)交给 net.Listen 报告
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			os.Remove(path)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewNetListener(l), nil
+}
+
+// ListenTLS 监听TLS地址，证书文件更新后新的连接自动使用新证书
+// config 为nil时使用默认配置，其中的 Certificates 与 GetCertificate 会被覆盖
+func ListenTLS(address, certFile, keyFile string, config *tls.Config) (*NetListener, error) {
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.Certificates = nil
+	config.GetCertificate = reloader.GetCertificate
+	l, err := tls.Listen("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewNetListener(l), nil
+}
+
+// CertReloader 在证书或私钥文件修改后重新加载证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新加载证书，加载失败时保留原证书
+func (r *CertReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetCertificate 用于 tls.Config.GetCertificate，文件有更新时先重新加载
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if modTime, err := r.latestModTime(); err == nil {
+		r.mu.RLock()
+		changed := modTime.After(r.modTime)
+		r.mu.RUnlock()
+		if changed {
+			// 证书与私钥可能尚未全部写入，加载失败时继续使用原证书
+			r.Reload()
+		}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	cert, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	key, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if key.ModTime().After(cert.ModTime()) {
+		return key.ModTime(), nil
+	}
+	return cert.ModTime(), nil
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// Use 与 UseOutbound 对之后建立的连接生效
+func TestServerUse(t *testing.T) {
+	m, l, action := newTestServer(t, map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		r.GetConnection().SendMsg(2, r.GetData())
+	})})
+	m.Use(func(next connection.Handler) connection.Handler {
+		return connection.HandlerFunc(func(r connection.IRequest) {
+			r.GetConnection().StoreProperty("middleware", true)
+			next.Handle(r)
+		})
+	})
+	m.UseOutbound(func(next connection.SendFunc) connection.SendFunc {
+		return func(ctx context.Context, conn *connection.Connection, message *protocol.Message) error {
+			if _, ok := conn.LoadProperty("middleware"); !ok {
+				return next(ctx, conn, message)
+			}
+			r := *message
+			r.Data = append(r.Data, "!"...)
+			return next(ctx, conn, &r)
+		}
+	})
+
+	got := make(chan []byte, 1)
+	client, _ := dial(t, l, action, map[uint32]connection.Handler{2: connection.HandlerFunc(func(r connection.IRequest) {
+		got <- r.GetData()
+	})})
+	if err := client.GetConnection().SendMsg(1, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "hi!" {
+		t.Fatalf("data = %q", data)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log/slog"
+	"net/netip"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/internal/ratelimit"
+	"github.com/s84662355/simple-message/metrics"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// Option 服务配置项
+type Option func(*Server)
+
+// WithConnOptions 追加每个连接使用的配置项
+func WithConnOptions(opts ...connection.Option) Option {
+	return func(m *Server) {
+		m.connOptions = append(m.connOptions, opts...)
+	}
+}
+
+// WithCodec 指定消息编解码器，指定后 maxDataLen 由编解码器自行约束
+func WithCodec(codec protocol.Codec) Option {
+	return WithConnOptions(connection.WithCodec(codec))
+}
+
+// WithWorkerPool 所有连接共享一个有界工作池处理消息，队列已满时阻塞连接读取形成背压
+// 工作池随服务停止而停止
+func WithWorkerPool(workers, queueSize int) Option {
+	return func(m *Server) {
+		m.workerPool = connection.NewWorkerPool(workers, queueSize)
+		m.connOptions = append(m.connOptions, connection.WithWorkerPool(m.workerPool))
+	}
+}
+
+// WithGoingAway Shutdown 时先通知对端连接即将关闭，对端连接错误回调收到 connection.ErrGoingAway
+func WithGoingAway() Option {
+	return func(m *Server) {
+		m.goingAway = true
+	}
+}
+
+// WithAuthenticator 连接认证通过后才调用 Action.ConnectedBegin，认证前只分发 whitelist 中的消息
+func WithAuthenticator(authenticator connection.Authenticator, timeout time.Duration, whitelist ...uint32) Option {
+	return WithConnOptions(connection.WithAuthenticator(authenticator, timeout, whitelist...))
+}
+
+// WithMaxConnsPerIP 单个对端IP的最大并发连接数，无法获取对端IP的连接不受此限制
+func WithMaxConnsPerIP(n int) Option {
+	return func(m *Server) {
+		m.limiter.perIP = n
+	}
+}
+
+// WithAcceptRate 按令牌桶限制新建连接的速率，每秒 rate 个，最多突发 burst 个
+func WithAcceptRate(rate float64, burst int) Option {
+	return func(m *Server) {
+		m.limiter.bucket = ratelimit.New(rate, burst)
+	}
+}
+
+// WithAllowList 只接受这些网段的连接，无法获取对端IP的连接一律拒绝
+func WithAllowList(prefixes ...netip.Prefix) Option {
+	return func(m *Server) {
+		m.limiter.allow = append(m.limiter.allow, prefixes...)
+	}
+}
+
+// WithDenyList 拒绝这些网段的连接，优先于 WithAllowList
+func WithDenyList(prefixes ...netip.Prefix) Option {
+	return func(m *Server) {
+		m.limiter.deny = append(m.limiter.deny, prefixes...)
+	}
+}
+
+// WithMetrics 将服务端及其连接的指标记录到sink
+func WithMetrics(sink metrics.Sink) Option {
+	return func(m *Server) {
+		m.metrics = sink
+		m.connOptions = append(m.connOptions, connection.WithMetrics(sink))
+	}
+}
+
+// WithLogger 将接受与拒绝连接、接受失败以及各连接的事件输出到logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Server) {
+		m.logger = logger
+		m.connOptions = append(m.connOptions, connection.WithLogger(logger))
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// panicAction ConnErr 中发生panic
+type panicAction struct {
+	*testAction
+}
+
+func (a panicAction) ConnErr(ctx context.Context, conn *connection.Connection, err error) {
+	a.errs <- err
+	panic("conn err")
+}
+
+// ConnErr 中的panic被捕获并回调 PanicHook，服务继续接受连接
+func TestPanicConnErr(t *testing.T) {
+	hooked := make(chan *connection.HandlerPanicError, 1)
+	l := newPipeListener()
+	action := panicAction{newTestAction()}
+	m := NewServer(l, nil, 0, 100, action, WithConnOptions(connection.WithPanicHook(
+		func(conn *connection.Connection, err *connection.HandlerPanicError) { hooked <- err },
+	)))
+	m.Start(1)
+	defer func() { <-m.Stop() }()
+
+	client, _ := dial(t, l, action.testAction, nil)
+	<-client.Stop()
+	recv(t, action.errs)
+	if err := recv(t, hooked); err.Value != "conn err" {
+		t.Fatalf("hook 收到 %+v", err)
+	}
+	dial(t, l, action.testAction, nil)
+}
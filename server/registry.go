@@ -0,0 +1,42 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// Connections 当前所有活跃连接
+func (m *Server) Connections() []*connection.Connection {
+	conns := make([]*connection.Connection, 0, m.connCount.Load())
+	m.Range(func(conn *connection.Connection) bool {
+		conns = append(conns, conn)
+		return true
+	})
+	return conns
+}
+
+// GetConnection 根据连接ID查找活跃连接
+func (m *Server) GetConnection(id uint64) (*connection.Connection, bool) {
+	if v, ok := m.conns.Load(id); ok {
+		return v.(*connection.HandlerManager).GetConnection(), true
+	}
+	return nil, false
+}
+
+// Range 遍历活跃连接，fn 返回false时停止遍历
+func (m *Server) Range(fn func(conn *connection.Connection) bool) {
+	m.conns.Range(func(_, v any) bool {
+		return fn(v.(*connection.HandlerManager).GetConnection())
+	})
+}
+
+// Kick 强制断开连接，连接错误回调收到包装了 ErrKicked 的错误
+func (m *Server) Kick(id uint64, reason string) error {
+	conn, ok := m.GetConnection(id)
+	if !ok {
+		return ErrConnNotFound
+	}
+	conn.CloseWithError(fmt.Errorf("%w: %s", ErrKicked, reason))
+	return nil
+}
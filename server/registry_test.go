@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	_, first := dial(t, l, action, nil)
+	_, second := dial(t, l, action, nil)
+
+	if n := len(m.Connections()); n != 2 {
+		t.Fatalf("连接数 = %d，期望 2", n)
+	}
+	conn, ok := m.GetConnection(second.ID())
+	if !ok || conn != second {
+		t.Fatalf("GetConnection(%d) = %v %v", second.ID(), conn, ok)
+	}
+	if first.ID() == second.ID() {
+		t.Fatalf("连接ID重复: %d", first.ID())
+	}
+}
+
+// Kick 断开连接，连接错误回调收到包装了 ErrKicked 的错误
+func TestKick(t *testing.T) {
+	m, l, action := newTestServer(t, nil)
+	client, conn := dial(t, l, action, nil)
+
+	if err := m.Kick(conn.ID(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv(t, action.errs); !errors.Is(err, ErrKicked) {
+		t.Fatalf("err = %v，期望 ErrKicked", err)
+	}
+	waitErr(t, client)
+	eventually(t, func() bool {
+		_, ok := m.GetConnection(conn.ID())
+		return !ok
+	})
+	if err := m.Kick(conn.ID(), "test"); !errors.Is(err, ErrConnNotFound) {
+		t.Fatalf("err = %v，期望 ErrConnNotFound", err)
+	}
+}
@@ -2,10 +2,20 @@ package server
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"slices"
 	"sync"
 	"sync/atomic"
 
 	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/internal/logging"
+	"github.com/s84662355/simple-message/metrics"
+)
+
+var (
+	ErrConnNotFound = errors.New("连接不存在")
+	ErrKicked       = errors.New("连接被踢下线")
 )
 
 type Server struct {
@@ -21,6 +31,15 @@ type Server struct {
 	maxConnCount int32
 	connCount    atomic.Int32
 	done         chan struct{}
+	connOptions  []connection.Option
+	workerPool   *connection.WorkerPool
+	conns        sync.Map
+	groups       groups
+	shutdown     atomic.Bool
+	goingAway    bool
+	limiter      limiter
+	metrics      metrics.Sink
+	logger       *slog.Logger
 }
 
 func NewServer(
@@ -29,6 +48,7 @@ func NewServer(
 	maxDataLen uint32,
 	maxConnCount int32,
 	action Action,
+	opts ...Option,
 ) *Server {
 	m := &Server{
 		listener:     listener,
@@ -37,6 +57,15 @@ func NewServer(
 		maxDataLen:   maxDataLen,
 		maxConnCount: maxConnCount,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.metrics == nil {
+		m.metrics = metrics.Discard
+	}
+	if m.logger == nil {
+		m.logger = logging.Discard
+	}
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 	m.done = make(chan struct{})
 	return m
@@ -50,6 +79,9 @@ func (m *Server) Start(acceptAmount int) <-chan struct{} {
 		m.isRun.Store(true)
 		go func() {
 			defer close(m.done)
+			if m.workerPool != nil {
+				defer func() { <-m.workerPool.Stop() }()
+			}
 			wg := &sync.WaitGroup{}
 			defer wg.Wait()
 			wg.Add(acceptAmount)
@@ -78,3 +110,60 @@ func (m *Server) Stop() <-chan struct{} {
 	m.cancel()
 	return m.done
 }
+
+// Shutdown 优雅停止服务: 停止接受新连接，各连接不再处理新的请求，等待进行中的处理器完成并发送完待发送的消息后关闭
+// ctx结束时强制关闭剩余连接并立即返回ctx的错误，未返回的处理器与连接错误回调在后台结束，可通过 Start 返回的通道等待
+func (m *Server) Shutdown(ctx context.Context) error {
+	m.statusMu.Lock()
+	if !m.isRun.Load() {
+		m.statusMu.Unlock()
+		return nil
+	}
+	m.isRun.Store(false)
+	m.shutdown.Store(true)
+	m.statusMu.Unlock()
+
+	m.listener.Close()
+
+	var forced atomic.Bool
+	wg := &sync.WaitGroup{}
+	m.conns.Range(func(_, v any) bool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := v.(*connection.HandlerManager).Shutdown(ctx, m.goingAway); err != nil {
+				forced.Store(true)
+			}
+		}()
+		return true
+	})
+	wg.Wait()
+
+	// 关闭期间新建立的连接直接关闭
+	m.cancel()
+	if forced.Load() {
+		return ctx.Err()
+	}
+	<-m.done
+	return nil
+}
+
+// Use 追加消息处理器中间件，对之后建立的连接生效
+func (m *Server) Use(middlewares ...connection.Middleware) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	m.connOptions = append(m.connOptions, connection.WithMiddleware(middlewares...))
+}
+
+// UseOutbound 追加出站拦截器，对之后建立的连接生效
+func (m *Server) UseOutbound(interceptors ...connection.SendInterceptor) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	m.connOptions = append(m.connOptions, connection.WithSendInterceptor(interceptors...))
+}
+
+func (m *Server) getConnOptions() []connection.Option {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	return slices.Clone(m.connOptions)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// Shutdown 通知对端即将关闭，等待进行中的处理器完成并发送完消息后关闭连接
+func TestShutdownDrainsHandlers(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m, l, action := newTestServer(t, map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		close(started)
+		<-release
+		r.GetConnection().SendMsg(2, []byte("done"))
+	})}, WithGoingAway())
+
+	got := make(chan []byte, 1)
+	client, _ := dial(t, l, action, map[uint32]connection.Handler{2: connection.HandlerFunc(func(r connection.IRequest) {
+		got <- r.GetData()
+	})})
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, started)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- m.Shutdown(ctx) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("处理器未完成时 Shutdown 已返回: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := recv(t, shutdown); err != nil {
+		t.Fatal(err)
+	}
+	if data := recv(t, got); string(data) != "done" {
+		t.Fatalf("data = %q", data)
+	}
+	if err := waitErr(t, client); !errors.Is(err, connection.ErrGoingAway) {
+		t.Fatalf("err = %v，期望 connection.ErrGoingAway", err)
+	}
+}
+
+// ctx结束时强制关闭剩余连接并返回ctx的错误
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	m, l, action := newTestServer(t, map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		close(started)
+		// 强制关闭时取消请求的ctx
+		<-r.Context().Done()
+	})})
+	client, _ := dial(t, l, action, nil)
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, started)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v，期望 context.DeadlineExceeded", err)
+	}
+	waitErr(t, client)
+}
+
+// 处理器忽略请求的ctx时，Shutdown 同样在ctx结束时返回
+func TestShutdownDeadlineIgnoredContext(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	m, l, action := newTestServer(t, map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		close(started)
+		<-release
+	})})
+	client, _ := dial(t, l, action, nil)
+	if err := client.GetConnection().SendMsg(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	recv(t, started)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v，期望 context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Shutdown 耗时 %v", d)
+	}
+	// 强制关闭了底层连接，客户端随之断开
+	waitErr(t, client)
+}
+
+// 服务停止时停止共享工作池
+func TestStopWorkerPool(t *testing.T) {
+	m, _, _ := newTestServer(t, nil, WithWorkerPool(1, 1))
+	<-m.Stop()
+	if err := m.workerPool.Submit(context.Background(), func() {}); !errors.Is(err, connection.ErrIsClose) {
+		t.Fatalf("err = %v，期望 connection.ErrIsClose", err)
+	}
+}
@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+var ErrFrame = errors.New("WebSocket消息格式错误")
+
+// frameOverhead 数据之外的长度: 4字节MsgID，以及扩展帧的1字节标志、4字节MsgID与4字节Seq
+const frameOverhead = protocol.HeaderDataLen + 1 + protocol.HeaderDataLen + 4
+
+// readLimit maxDataLen 对应的单条WebSocket消息长度上限，超过时底层连接在读入内存之前关闭
+func readLimit(maxDataLen uint32) int64 {
+	if maxDataLen == 0 {
+		maxDataLen = protocol.MaxDataLen
+	}
+	return int64(maxDataLen) + int64(frameOverhead)
+}
+
+// Codec WebSocket编解码器，WebSocket消息自带长度，帧格式为 4字节MsgID(大端序) + 数据
+// 只能用于 Conn，发送协程不能合并多个帧，使用 ConnOptions 获取配套的连接配置
+type Codec struct {
+	maxDataLen uint32
+}
+
+func NewCodec(maxDataLen uint32) *Codec {
+	if maxDataLen == 0 {
+		maxDataLen = protocol.MaxDataLen
+	}
+	return &Codec{maxDataLen: maxDataLen}
+}
+
+func (c *Codec) MaxDataLen() uint32 {
+	return c.maxDataLen
+}
+
+func (c *Codec) Unmarshal(conn io.Reader) (*protocol.Message, error) {
+	r, ok := conn.(interface{ ReadMessage() ([]byte, error) })
+	if !ok {
+		return nil, fmt.Errorf("%w: 连接不是WebSocket连接", ErrFrame)
+	}
+	b, err := r.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < int(protocol.HeaderDataLen) {
+		return nil, ErrFrame
+	}
+	if uint32(len(b))-protocol.HeaderDataLen > c.maxDataLen {
+		return nil, fmt.Errorf("%w 不得大于%d", protocol.ErrDataLength, c.maxDataLen)
+	}
+	return &protocol.Message{
+		MsgID: binary.BigEndian.Uint32(b),
+		Data:  b[protocol.HeaderDataLen:],
+	}, nil
+}
+
+func (c *Codec) MarshalMessage(conn io.Writer, message *protocol.Message) error {
+	n := uint32(len(message.Data))
+	if n > c.maxDataLen {
+		return fmt.Errorf("%w 不得大于%d", protocol.ErrDataLength, c.maxDataLen)
+	}
+	b := make([]byte, protocol.HeaderDataLen+n)
+	binary.BigEndian.PutUint32(b, message.MsgID)
+	copy(b[protocol.HeaderDataLen:], message.Data)
+	_, err := conn.Write(b)
+	return err
+}
+
+// ConnOptions WebSocket连接需要的配置: 使用 Codec 编解码，每次写入只发送一个帧
+func ConnOptions(maxDataLen uint32) []connection.Option {
+	return []connection.Option{
+		connection.WithCodec(NewCodec(maxDataLen)),
+		connection.WithWriteBatch(1),
+	}
+}
+
+var (
+	_ protocol.Codec       = (*Codec)(nil)
+	_ protocol.DataLimiter = (*Codec)(nil)
+)
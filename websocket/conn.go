@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+)
+
+const closeTimeout = time.Second
+
+// Conn 将 WebSocket 连接适配为 connection.Conn，每次 Write 发送一条二进制消息
+// 配合 Codec 使用时每条 WebSocket 消息恰好是一个协议帧
+type Conn struct {
+	conn      *gws.Conn
+	readBuf   []byte
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func NewConn(conn *gws.Conn) *Conn {
+	return &Conn{conn: conn}
+}
+
+// ReadMessage 读取一条完整的消息，由 Codec 调用，不能与 Read 混用
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		messageType, b, err := c.conn.ReadMessage()
+		if err != nil {
+			if gws.IsCloseError(err, gws.CloseNormalClosure, gws.CloseGoingAway) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		if messageType == gws.BinaryMessage {
+			return b, nil
+		}
+	}
+}
+
+// Read 按字节流读取，供带长度前缀的编解码器使用
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.readBuf) == 0 {
+		b, err := c.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.readBuf = b
+	}
+	n := copy(p, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.conn.WriteMessage(gws.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 发送关闭帧后关闭底层连接，可以与 Write 并发调用
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.conn.WriteControl(gws.CloseMessage, gws.FormatCloseMessage(gws.CloseNormalClosure, ""), time.Now().Add(closeTimeout))
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Subprotocol 协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+// WebSocket 底层的 WebSocket 连接
+func (c *Conn) WebSocket() *gws.Conn {
+	return c.conn
+}
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/s84662355/simple-message/client"
+	"github.com/s84662355/simple-message/connection"
+)
+
+var ErrSubprotocol = errors.New("服务端未接受任何子协议")
+
+// Dialer WebSocket拨号器，实现 client.Dialer，可以嵌入到 client.Action 的实现中
+// 指定 Subprotocols 时服务端必须接受其中一个，否则拨号失败
+type Dialer struct {
+	URL          string
+	Header       http.Header
+	Subprotocols []string
+	Dialer       *gws.Dialer // 为nil时使用 gws.DefaultDialer
+	MaxDataLen   uint32      // 与 ClientOption 使用相同的 maxDataLen，为0时按 protocol.MaxDataLen 计算消息长度上限
+	ReadLimit    int64       // 单条WebSocket消息的最大长度，优先于 MaxDataLen
+	Data         any
+}
+
+func NewDialer(url string, subprotocols ...string) *Dialer {
+	return &Dialer{
+		URL:          url,
+		Subprotocols: subprotocols,
+	}
+}
+
+func (d *Dialer) DialContext(ctx context.Context) (connection.Conn, any, error) {
+	dialer := gws.DefaultDialer
+	if d.Dialer != nil {
+		dialer = d.Dialer
+	}
+	if len(d.Subprotocols) > 0 {
+		cp := *dialer
+		cp.Subprotocols = d.Subprotocols
+		dialer = &cp
+	}
+	ws, _, err := dialer.DialContext(ctx, d.URL, d.Header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(d.Subprotocols) > 0 && ws.Subprotocol() == "" {
+		ws.Close()
+		return nil, nil, ErrSubprotocol
+	}
+	limit := d.ReadLimit
+	if limit <= 0 {
+		limit = readLimit(d.MaxDataLen)
+	}
+	ws.SetReadLimit(limit)
+	return NewConn(ws), d.Data, nil
+}
+
+var _ client.Dialer = (*Dialer)(nil)
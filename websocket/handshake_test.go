@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s84662355/simple-message/connection"
+)
+
+// 开启握手的一端在超时前未收到旧版本对端的帧，超时后连接仍可正常读取
+func TestHandshakeLegacyPeer(t *testing.T) {
+	l := NewListener()
+	defer l.Close()
+	srv := httptest.NewServer(l)
+	defer srv.Close()
+
+	got := make(chan string, 1)
+	handler := map[uint32]connection.Handler{1: connection.HandlerFunc(func(r connection.IRequest) {
+		got <- string(r.GetData())
+	})}
+	begin := func(ctx context.Context, conn *connection.Connection) { <-ctx.Done() }
+
+	accepted := make(chan *connection.HandlerManager, 1)
+	go func() {
+		conn, _, err := l.Accept()
+		if err != nil {
+			return
+		}
+		opts := append(ConnOptions(0), connection.WithHandshake(50*time.Millisecond))
+		accepted <- connection.NewHandlerManager(conn, handler, 0, begin, nil, opts...)
+	}()
+
+	conn, _, err := NewDialer("ws" + strings.TrimPrefix(srv.URL, "http")).DialContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := connection.NewHandlerManager(conn, nil, 0, begin, nil, ConnOptions(0)...)
+	defer func() { <-legacy.Stop() }()
+
+	var server *connection.HandlerManager
+	select {
+	case server = <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待超时")
+	}
+	defer func() { <-server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+	if hs := server.GetConnection().Handshake(); hs == nil || !hs.Legacy {
+		t.Fatalf("握手结果 %+v，期望 Legacy", hs)
+	}
+	if err := legacy.GetConnection().SendMsg(1, []byte("legacy")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-got:
+		if data != "legacy" {
+			t.Fatalf("收到 %q", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("未收到消息: %v", server.Ctx().Err())
+	}
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/s84662355/simple-message/connection"
+	"github.com/s84662355/simple-message/server"
+)
+
+// Listener WebSocket监听器，实现 server.Listener 和 http.Handler
+// 可以挂载到已有的 http.ServeMux，也可以通过 Listen 独立监听
+// Accept 返回的连接数据为升级请求 *http.Request
+type Listener struct {
+	upgrader  gws.Upgrader
+	readLimit int64
+	conns     chan accepted
+	closed    chan struct{}
+	closeOnce sync.Once
+	server    *http.Server
+}
+
+type accepted struct {
+	conn *Conn
+	req  *http.Request
+}
+
+func NewListener(opts ...Option) *Listener {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	l := &Listener{
+		upgrader: gws.Upgrader{
+			ReadBufferSize:  cfg.readBufferSize,
+			WriteBufferSize: cfg.writeBufferSize,
+			Subprotocols:    cfg.subprotocols,
+			CheckOrigin:     cfg.checkOrigin,
+		},
+		readLimit: cfg.readLimit,
+		conns:     make(chan accepted),
+		closed:    make(chan struct{}),
+	}
+	if l.readLimit <= 0 {
+		l.readLimit = readLimit(cfg.maxDataLen)
+	}
+	if l.upgrader.CheckOrigin == nil && len(cfg.origins) > 0 {
+		l.upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || slices.Contains(cfg.origins, "*") || slices.Contains(cfg.origins, origin)
+		}
+	}
+	return l
+}
+
+// Listen 在 address 上启动HTTP服务，path 为WebSocket路径，Close 时关闭HTTP服务
+func Listen(address, path string, opts ...Option) (*Listener, error) {
+	tcpListener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	l := NewListener(opts...)
+	mux := http.NewServeMux()
+	mux.Handle(path, l)
+	l.server = &http.Server{Handler: mux}
+	go func() {
+		if err := l.server.Serve(tcpListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Close()
+		}
+	}()
+	return l, nil
+}
+
+// ServeHTTP 将请求升级为WebSocket连接，等待 Accept 取走
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-l.closed:
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	ws, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已经回复了错误
+		return
+	}
+	ws.SetReadLimit(l.readLimit)
+	conn := NewConn(ws)
+	select {
+	case l.conns <- accepted{conn: conn, req: r}:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+func (l *Listener) Accept() (connection.Conn, any, error) {
+	select {
+	case a := <-l.conns:
+		return a.conn, a.req, nil
+	case <-l.closed:
+		return nil, nil, net.ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		if l.server != nil {
+			l.server.Close()
+		}
+	})
+	return nil
+}
+
+var (
+	_ server.Listener = (*Listener)(nil)
+	_ http.Handler    = (*Listener)(nil)
+)
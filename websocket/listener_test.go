@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/s84662355/simple-message/protocol"
+)
+
+// 超过长度上限的消息在读入内存之前被拒绝
+func TestListenerReadLimit(t *testing.T) {
+	l := NewListener(WithMaxDataLen(16))
+	defer l.Close()
+	srv := httptest.NewServer(l)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ws, _, err := gws.DefaultDialer.DialContext(context.Background(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	conn, _, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	codec := NewCodec(16)
+
+	ok := make([]byte, protocol.HeaderDataLen+16)
+	if err := ws.WriteMessage(gws.BinaryMessage, ok); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.Unmarshal(conn); err != nil {
+		t.Fatalf("未超过上限的消息读取失败: %v", err)
+	}
+
+	if err := ws.WriteMessage(gws.BinaryMessage, make([]byte, 1<<20)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := codec.Unmarshal(conn)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, gws.ErrReadLimit) {
+			t.Fatalf("err = %v，期望 %v", err, gws.ErrReadLimit)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待超时")
+	}
+}
@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"net/http"
+
+	"github.com/s84662355/simple-message/client"
+	"github.com/s84662355/simple-message/server"
+)
+
+type config struct {
+	origins         []string
+	checkOrigin     func(r *http.Request) bool
+	subprotocols    []string
+	readBufferSize  int
+	writeBufferSize int
+	readLimit       int64
+	maxDataLen      uint32
+}
+
+// Option 监听器配置项
+type Option func(*config)
+
+// WithOrigins 允许的Origin，"*" 表示允许所有，不指定时只允许与Host相同的Origin
+func WithOrigins(origins ...string) Option {
+	return func(c *config) {
+		c.origins = append(c.origins, origins...)
+	}
+}
+
+// WithCheckOrigin 自定义Origin检查，优先于 WithOrigins
+func WithCheckOrigin(check func(r *http.Request) bool) Option {
+	return func(c *config) {
+		c.checkOrigin = check
+	}
+}
+
+// WithSubprotocols 服务端支持的子协议，按优先顺序排列，协商结果通过 Conn.Subprotocol 获取
+func WithSubprotocols(subprotocols ...string) Option {
+	return func(c *config) {
+		c.subprotocols = append(c.subprotocols, subprotocols...)
+	}
+}
+
+// WithBufferSize 读写缓冲区大小
+func WithBufferSize(read, write int) Option {
+	return func(c *config) {
+		c.readBufferSize = read
+		c.writeBufferSize = write
+	}
+}
+
+// WithMaxDataLen 与 ServerOption 使用相同的 maxDataLen，单条WebSocket消息的长度上限据此计算
+// 不指定时按 protocol.MaxDataLen 计算
+func WithMaxDataLen(maxDataLen uint32) Option {
+	return func(c *config) {
+		c.maxDataLen = maxDataLen
+	}
+}
+
+// WithReadLimit 单条WebSocket消息的最大长度，超过时关闭连接，优先于 WithMaxDataLen
+func WithReadLimit(limit int64) Option {
+	return func(c *config) {
+		c.readLimit = limit
+	}
+}
+
+// ServerOption 服务端使用WebSocket编解码器
+func ServerOption(maxDataLen uint32) server.Option {
+	return server.WithConnOptions(ConnOptions(maxDataLen)...)
+}
+
+// ClientOption 客户端使用WebSocket编解码器
+func ClientOption(maxDataLen uint32) client.Option {
+	return client.WithConnOptions(ConnOptions(maxDataLen)...)
+}